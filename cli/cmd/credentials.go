@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"github.com/pritunl/pritunl-client-electron/cli/sprofile"
+	"github.com/spf13/cobra"
+)
+
+var CredentialsCmd = &cobra.Command{
+	Use:   "credentials",
+	Short: "Manage saved profile passwords",
+	Run: func(cmd *cobra.Command, args []string) {
+		err := cmd.Help()
+		cobra.CheckErr(err)
+	},
+}
+
+var CredentialsClearCmd = &cobra.Command{
+	Use:   "clear [profile_id]",
+	Short: "Remove saved profile password",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			cobra.CheckErr("cmd: Missing profile ID")
+		}
+
+		err := sprofile.ClearCredentials(args[0])
+		cobra.CheckErr(err)
+	},
+}
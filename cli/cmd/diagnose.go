@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pritunl/pritunl-client-electron/cli/errortypes"
+	"github.com/pritunl/pritunl-client-electron/cli/sprofile"
+	"github.com/spf13/cobra"
+)
+
+var DiagnoseCmd = &cobra.Command{
+	Use:   "diagnose [profile_id]",
+	Short: "Run connectivity and DNS leak checks for connected profile",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			cobra.CheckErr("cmd: Missing profile ID")
+		}
+
+		sprfl, err := sprofile.Match(args[0])
+		cobra.CheckErr(err)
+
+		report, err := sprfl.Diagnose()
+		cobra.CheckErr(err)
+
+		if jsonFormat || jsonFormated {
+			var output []byte
+			if jsonFormated {
+				output, err = json.MarshalIndent(report, "", "  ")
+			} else {
+				output, err = json.Marshal(report)
+			}
+			if err != nil {
+				err = &errortypes.ParseError{
+					errors.Wrap(err, "cmd: Failed to marshal report"),
+				}
+				cobra.CheckErr(err)
+			}
+
+			fmt.Println(string(output))
+			return
+		}
+
+		fmt.Printf("Profile: %s\n", sprfl.FormatedName())
+		fmt.Printf("Mode: %s\n", report.Mode)
+		fmt.Printf("Interface: %s\n", report.Iface)
+		fmt.Printf("Time: %s\n", time.Unix(report.Timestamp, 0).Format(
+			"2006-01-02 15:04:05"))
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{
+			"Check",
+			"Result",
+			"Details",
+		})
+		table.SetBorder(true)
+		table.SetAutoWrapText(false)
+
+		for _, check := range report.Checks {
+			table.Append([]string{
+				check.Name,
+				strings.ToUpper(check.Status),
+				check.Message,
+			})
+		}
+
+		table.Render()
+
+		fmt.Printf("Result: %s\n", strings.ToUpper(report.Status))
+	},
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/pritunl/pritunl-client-electron/cli/sprofile"
+	"github.com/pritunl/pritunl-client-electron/cli/terminal"
+	"github.com/spf13/cobra"
+)
+
+var ExportCmd = &cobra.Command{
+	Use:   "export [profile_id]",
+	Short: "Export profiles",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 && !exportAll {
+			cobra.CheckErr("cmd: Missing profile ID or --all")
+		}
+
+		if exportTar == "" && (exportAll || exportEncrypt) {
+			cobra.CheckErr("cmd: Export of all profiles or " +
+				"encrypted export requires --tar")
+		}
+
+		var sprfls []*sprofile.Sprofile
+		if exportAll {
+			allSprfls, err := sprofile.GetAll()
+			cobra.CheckErr(err)
+			sprfls = allSprfls
+		} else {
+			sprfl, err := sprofile.Match(args[0])
+			cobra.CheckErr(err)
+			sprfls = []*sprofile.Sprofile{sprfl}
+		}
+
+		if exportTar == "" {
+			data, err := sprfls[0].Export()
+			cobra.CheckErr(err)
+			fmt.Print(data)
+			return
+		}
+
+		passphrase := ""
+		if exportEncrypt {
+			passphrase = terminal.ReadPassword("Passphrase")
+			if passphrase == "" {
+				cobra.CheckErr("cmd: Passphrase is empty")
+			}
+
+			if terminal.ReadPassword("Confirm Passphrase") != passphrase {
+				cobra.CheckErr("cmd: Passphrases do not match")
+			}
+		}
+
+		err := sprofile.ExportTar(sprfls, exportTar, passphrase)
+		cobra.CheckErr(err)
+
+		fmt.Printf("Exported %d profiles to %s\n", len(sprfls), exportTar)
+	},
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pritunl/pritunl-client-electron/cli/errortypes"
+	"github.com/pritunl/pritunl-client-electron/cli/sprofile"
+	"github.com/spf13/cobra"
+)
+
+var HistoryCmd = &cobra.Command{
+	Use:   "history [profile_id]",
+	Short: "Show connection history for profile",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			cobra.CheckErr("cmd: Missing profile ID")
+		}
+
+		sprfl, err := sprofile.Match(args[0])
+		cobra.CheckErr(err)
+
+		entries, err := sprfl.GetHistory()
+		cobra.CheckErr(err)
+
+		if jsonFormat || jsonFormated {
+			var output []byte
+			if jsonFormated {
+				output, err = json.MarshalIndent(entries, "", "  ")
+			} else {
+				output, err = json.Marshal(entries)
+			}
+			if err != nil {
+				err = &errortypes.ParseError{
+					errors.Wrap(err, "cmd: Failed to marshal history"),
+				}
+				cobra.CheckErr(err)
+			}
+
+			fmt.Println(string(output))
+			return
+		}
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{
+			"Time",
+			"Event",
+			"Mode",
+			"Remote",
+			"Reason",
+			"Duration",
+		})
+		table.SetBorder(true)
+
+		for _, entry := range entries {
+			duration := "-"
+			if entry.Duration != 0 {
+				duration = (time.Duration(entry.Duration*1000) *
+					time.Millisecond).Round(time.Second).String()
+			}
+
+			table.Append([]string{
+				time.Unix(entry.Timestamp, 0).Format("2006-01-02 15:04:05"),
+				entry.Event,
+				entry.Mode,
+				entry.Remote,
+				entry.Reason,
+				duration,
+			})
+		}
+
+		table.Render()
+	},
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/pritunl/pritunl-client-electron/cli/sprofile"
+	"github.com/pritunl/pritunl-client-electron/cli/terminal"
+	"github.com/spf13/cobra"
+)
+
+var RestoreCmd = &cobra.Command{
+	Use:   "restore [tar_path|conf_path]",
+	Short: "Restore exported profiles",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			cobra.CheckErr("cmd: Missing export path")
+		}
+
+		restored, err := sprofile.RestoreFile(args[0], func() string {
+			passphrase := terminal.ReadPassword("Passphrase")
+			if passphrase == "" {
+				cobra.CheckErr("cmd: Passphrase is empty")
+			}
+			return passphrase
+		})
+		cobra.CheckErr(err)
+
+		fmt.Printf("Restored %d profiles\n", restored)
+	},
+}
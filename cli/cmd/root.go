@@ -24,14 +24,22 @@ func Execute() {
 }
 
 func init() {
+	CredentialsCmd.AddCommand(CredentialsClearCmd)
+
 	RootCmd.AddCommand(VersionCmd)
 	RootCmd.AddCommand(AddCmd)
+	RootCmd.AddCommand(ExportCmd)
+	RootCmd.AddCommand(RestoreCmd)
 	RootCmd.AddCommand(RemoveCmd)
 	RootCmd.AddCommand(EnableCmd)
 	RootCmd.AddCommand(DisableCmd)
 	RootCmd.AddCommand(LogsCmd)
+	RootCmd.AddCommand(HistoryCmd)
+	RootCmd.AddCommand(DiagnoseCmd)
+	RootCmd.AddCommand(RoutesCmd)
 	RootCmd.AddCommand(ListCmd)
 	RootCmd.AddCommand(StartCmd)
 	RootCmd.AddCommand(StopCmd)
+	RootCmd.AddCommand(CredentialsCmd)
 	RootCmd.AddCommand(WatchCmd)
 }
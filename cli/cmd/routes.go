@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pritunl/pritunl-client-electron/cli/sprofile"
+	"github.com/spf13/cobra"
+)
+
+func removeRoutes(routes, remove []string) (result []string) {
+	result = []string{}
+
+	for _, route := range routes {
+		found := false
+		for _, rem := range remove {
+			if route == rem {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			result = append(result, route)
+		}
+	}
+
+	return
+}
+
+var RoutesCmd = &cobra.Command{
+	Use:   "routes [profile_id]",
+	Short: "Show or modify custom routes for profile",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			cobra.CheckErr("cmd: Missing profile ID")
+		}
+
+		sprfl, err := sprofile.Match(args[0])
+		cobra.CheckErr(err)
+
+		includes := sprfl.RouteIncludes
+		if includes == nil {
+			includes = []string{}
+		}
+		excludes := sprfl.RouteExcludes
+		if excludes == nil {
+			excludes = []string{}
+		}
+
+		modified := false
+		if routesClear {
+			includes = []string{}
+			excludes = []string{}
+			modified = true
+		}
+		if len(routesRemoveInclude) > 0 {
+			includes = removeRoutes(includes, routesRemoveInclude)
+			modified = true
+		}
+		if len(routesRemoveExclude) > 0 {
+			excludes = removeRoutes(excludes, routesRemoveExclude)
+			modified = true
+		}
+		if len(routesAddInclude) > 0 {
+			includes = append(removeRoutes(
+				includes, routesAddInclude), routesAddInclude...)
+			modified = true
+		}
+		if len(routesAddExclude) > 0 {
+			excludes = append(removeRoutes(
+				excludes, routesAddExclude), routesAddExclude...)
+			modified = true
+		}
+
+		if modified {
+			err = sprofile.SetRoutes(sprfl.Id, includes, excludes)
+			cobra.CheckErr(err)
+		}
+
+		fmt.Printf("Include: %s\n", strings.Join(includes, ", "))
+		fmt.Printf("Exclude: %s\n", strings.Join(excludes, ", "))
+	},
+}
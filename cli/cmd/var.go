@@ -6,6 +6,16 @@ var (
 	passwordPrompt bool
 	jsonFormat     bool
 	jsonFormated   bool
+
+	routesAddInclude    []string
+	routesAddExclude    []string
+	routesRemoveInclude []string
+	routesRemoveExclude []string
+	routesClear         bool
+
+	exportAll     bool
+	exportTar     string
+	exportEncrypt bool
 )
 
 func init() {
@@ -46,4 +56,91 @@ func init() {
 		false,
 		"Format output in indented JSON",
 	)
+
+	HistoryCmd.Flags().BoolVarP(
+		&jsonFormat,
+		"json",
+		"j",
+		false,
+		"Format output in JSON",
+	)
+
+	HistoryCmd.Flags().BoolVarP(
+		&jsonFormated,
+		"json-formatted",
+		"f",
+		false,
+		"Format output in indented JSON",
+	)
+
+	DiagnoseCmd.Flags().BoolVarP(
+		&jsonFormat,
+		"json",
+		"j",
+		false,
+		"Format output in JSON",
+	)
+
+	DiagnoseCmd.Flags().BoolVarP(
+		&jsonFormated,
+		"json-formatted",
+		"f",
+		false,
+		"Format output in indented JSON",
+	)
+
+	ExportCmd.Flags().BoolVarP(
+		&exportAll,
+		"all",
+		"a",
+		false,
+		"Export all profiles",
+	)
+	ExportCmd.Flags().StringVarP(
+		&exportTar,
+		"tar",
+		"t",
+		"",
+		"Write profiles to tar file",
+	)
+	ExportCmd.Flags().BoolVarP(
+		&exportEncrypt,
+		"encrypt",
+		"e",
+		false,
+		"Encrypt tar file with a passphrase",
+	)
+
+	RoutesCmd.Flags().StringSliceVarP(
+		&routesAddInclude,
+		"include",
+		"i",
+		nil,
+		"Add network to route through the tunnel",
+	)
+	RoutesCmd.Flags().StringSliceVarP(
+		&routesAddExclude,
+		"exclude",
+		"e",
+		nil,
+		"Add network to exclude from the tunnel",
+	)
+	RoutesCmd.Flags().StringSliceVar(
+		&routesRemoveInclude,
+		"remove-include",
+		nil,
+		"Remove included network",
+	)
+	RoutesCmd.Flags().StringSliceVar(
+		&routesRemoveExclude,
+		"remove-exclude",
+		nil,
+		"Remove excluded network",
+	)
+	RoutesCmd.Flags().BoolVar(
+		&routesClear,
+		"clear",
+		false,
+		"Clear all custom routes",
+	)
 }
@@ -0,0 +1,76 @@
+// Storage for saved profile passwords in the user keyring.
+package credentials
+
+import (
+	"sync"
+)
+
+type Store interface {
+	Get(prflId string) (password string, err error)
+	Set(prflId, password string) (err error)
+	Remove(prflId string) (err error)
+}
+
+var (
+	store       Store
+	storeLoaded bool
+	storeLock   = sync.Mutex{}
+)
+
+func getStore() Store {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+
+	if !storeLoaded {
+		store = newKeyring()
+		storeLoaded = true
+	}
+
+	return store
+}
+
+func Get(prflId string) (password string, err error) {
+	str := getStore()
+	if str == nil {
+		return
+	}
+
+	password, err = str.Get(prflId)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func Set(prflId, password string) (err error) {
+	str := getStore()
+	if str == nil {
+		return
+	}
+
+	if password == "" {
+		err = str.Remove(prflId)
+	} else {
+		err = str.Set(prflId, password)
+	}
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func Remove(prflId string) (err error) {
+	str := getStore()
+	if str == nil {
+		return
+	}
+
+	err = str.Remove(prflId)
+	if err != nil {
+		return
+	}
+
+	return
+}
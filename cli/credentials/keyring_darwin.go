@@ -0,0 +1,5 @@
+package credentials
+
+func newKeyring() Store {
+	return nil
+}
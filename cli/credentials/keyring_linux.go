@@ -0,0 +1,240 @@
+package credentials
+
+import (
+	"os"
+	"sync"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/godbus/dbus/v5"
+	"github.com/pritunl/pritunl-client-electron/cli/errortypes"
+)
+
+const (
+	secretsDest       = "org.freedesktop.secrets"
+	secretsPath       = "/org/freedesktop/secrets"
+	secretsService    = "org.freedesktop.Secret.Service"
+	secretsCollection = "org.freedesktop.Secret.Collection"
+	secretsItem       = "org.freedesktop.Secret.Item"
+	secretsNoPrompt   = dbus.ObjectPath("/")
+	secretsApp        = "pritunl-client"
+)
+
+type secretData struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+// SecretService saves passwords to the default collection of the
+// freedesktop Secret Service on the session bus
+type SecretService struct {
+	conn    *dbus.Conn
+	session dbus.ObjectPath
+	lock    sync.Mutex
+}
+
+func newKeyring() Store {
+	if os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+		return nil
+	}
+
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil
+	}
+
+	var output dbus.Variant
+	var session dbus.ObjectPath
+	err = conn.Object(secretsDest, secretsPath).Call(
+		secretsService+".OpenSession", 0,
+		"plain",
+		dbus.MakeVariant(""),
+	).Store(&output, &session)
+	if err != nil {
+		_ = conn.Close()
+		return nil
+	}
+
+	return &SecretService{
+		conn:    conn,
+		session: session,
+	}
+}
+
+func (s *SecretService) attributes(prflId string) map[string]string {
+	return map[string]string{
+		"application": secretsApp,
+		"profile_id":  prflId,
+	}
+}
+
+func (s *SecretService) unlock(pths []dbus.ObjectPath) (err error) {
+	var unlocked []dbus.ObjectPath
+	var prompt dbus.ObjectPath
+
+	err = s.conn.Object(secretsDest, secretsPath).Call(
+		secretsService+".Unlock", 0,
+		pths,
+	).Store(&unlocked, &prompt)
+	if err != nil {
+		err = errortypes.ReadError{
+			errors.Wrap(err, "credentials: Failed to unlock keyring"),
+		}
+		return
+	}
+
+	if prompt != secretsNoPrompt {
+		err = errortypes.ReadError{
+			errors.New("credentials: Keyring is locked"),
+		}
+		return
+	}
+
+	return
+}
+
+func (s *SecretService) search(prflId string) (
+	items []dbus.ObjectPath, err error) {
+
+	var locked []dbus.ObjectPath
+
+	err = s.conn.Object(secretsDest, secretsPath).Call(
+		secretsService+".SearchItems", 0,
+		s.attributes(prflId),
+	).Store(&items, &locked)
+	if err != nil {
+		err = errortypes.ReadError{
+			errors.Wrap(err, "credentials: Failed to search keyring"),
+		}
+		return
+	}
+
+	if len(locked) > 0 {
+		err = s.unlock(locked)
+		if err != nil {
+			return
+		}
+		items = append(items, locked...)
+	}
+
+	return
+}
+
+func (s *SecretService) Get(prflId string) (password string, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	items, err := s.search(prflId)
+	if err != nil || len(items) == 0 {
+		return
+	}
+
+	secret := &secretData{}
+	err = s.conn.Object(secretsDest, items[0]).Call(
+		secretsItem+".GetSecret", 0,
+		s.session,
+	).Store(secret)
+	if err != nil {
+		err = errortypes.ReadError{
+			errors.Wrap(err, "credentials: Failed to read keyring secret"),
+		}
+		return
+	}
+
+	password = string(secret.Value)
+	return
+}
+
+func (s *SecretService) Set(prflId, password string) (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var collection dbus.ObjectPath
+	err = s.conn.Object(secretsDest, secretsPath).Call(
+		secretsService+".ReadAlias", 0,
+		"default",
+	).Store(&collection)
+	if err != nil {
+		err = errortypes.ReadError{
+			errors.Wrap(err, "credentials: Failed to read keyring collection"),
+		}
+		return
+	}
+
+	if collection == secretsNoPrompt {
+		err = errortypes.ReadError{
+			errors.New("credentials: Keyring has no default collection"),
+		}
+		return
+	}
+
+	err = s.unlock([]dbus.ObjectPath{collection})
+	if err != nil {
+		return
+	}
+
+	props := map[string]dbus.Variant{
+		secretsItem + ".Label": dbus.MakeVariant(
+			"Pritunl Client " + prflId),
+		secretsItem + ".Attributes": dbus.MakeVariant(
+			s.attributes(prflId)),
+	}
+
+	secret := secretData{
+		Session:     s.session,
+		Parameters:  []byte{},
+		Value:       []byte(password),
+		ContentType: "text/plain",
+	}
+
+	var item dbus.ObjectPath
+	var prompt dbus.ObjectPath
+	err = s.conn.Object(secretsDest, collection).Call(
+		secretsCollection+".CreateItem", 0,
+		props,
+		secret,
+		true,
+	).Store(&item, &prompt)
+	if err != nil {
+		err = errortypes.WriteError{
+			errors.Wrap(err, "credentials: Failed to write keyring secret"),
+		}
+		return
+	}
+
+	if prompt != secretsNoPrompt {
+		err = errortypes.WriteError{
+			errors.New("credentials: Keyring requires prompt to write"),
+		}
+		return
+	}
+
+	return
+}
+
+func (s *SecretService) Remove(prflId string) (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	items, err := s.search(prflId)
+	if err != nil {
+		return
+	}
+
+	for _, item := range items {
+		var prompt dbus.ObjectPath
+		err = s.conn.Object(secretsDest, item).Call(
+			secretsItem+".Delete", 0,
+		).Store(&prompt)
+		if err != nil {
+			err = errortypes.WriteError{
+				errors.Wrap(err,
+					"credentials: Failed to remove keyring secret"),
+			}
+			return
+		}
+	}
+
+	return
+}
@@ -5,8 +5,10 @@ go 1.17
 require (
 	github.com/dropbox/godropbox v0.0.0-20230623171840-436d2007a9fd
 	github.com/gizak/termui/v3 v3.1.0
+	github.com/godbus/dbus/v5 v5.1.0
 	github.com/olekukonko/tablewriter v0.0.5
 	github.com/spf13/cobra v1.8.1
+	golang.org/x/crypto v0.14.0
 	golang.org/x/term v0.24.0
 )
 
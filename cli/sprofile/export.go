@@ -0,0 +1,284 @@
+package sprofile
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/cli/errortypes"
+	"github.com/pritunl/pritunl-client-electron/cli/service"
+	"github.com/pritunl/pritunl-client-electron/cli/utils"
+)
+
+// Fields excluded from the exported conf header
+var exportExclude = []string{
+	"password",
+	"managed",
+	"ovpn_data",
+}
+
+// Export reconstitutes the profile conf with the json header used by
+// profile imports, the header also includes the profile ID, enabled state
+// and active state used by restore
+func (s *Sprofile) Export() (data string, err error) {
+	headerData, err := json.Marshal(s)
+	if err != nil {
+		err = errortypes.ParseError{
+			errors.Wrap(err, "sprofile: Json marshal error"),
+		}
+		return
+	}
+
+	header := map[string]interface{}{}
+	err = json.Unmarshal(headerData, &header)
+	if err != nil {
+		err = errortypes.ParseError{
+			errors.Wrap(err, "sprofile: Json unmarshal error"),
+		}
+		return
+	}
+
+	for _, key := range exportExclude {
+		delete(header, key)
+	}
+	if header["network_rules"] == nil {
+		delete(header, "network_rules")
+	}
+
+	headerData, err = json.MarshalIndent(header, "", "  ")
+	if err != nil {
+		err = errortypes.ParseError{
+			errors.Wrap(err, "sprofile: Json marshal error"),
+		}
+		return
+	}
+
+	output := &strings.Builder{}
+	for _, line := range strings.Split(string(headerData), "\n") {
+		output.WriteString("#" + line + "\n")
+	}
+	output.WriteString(strings.TrimLeft(s.OvpnData, "\n"))
+
+	data = output.String()
+	return
+}
+
+func ExportTar(sprfls []*Sprofile, filename, passphrase string) (
+	err error) {
+
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+
+	for _, sprfl := range sprfls {
+		data, e := sprfl.Export()
+		if e != nil {
+			err = e
+			return
+		}
+
+		err = tw.WriteHeader(&tar.Header{
+			Name:    sprfl.Id + ".ovpn",
+			Mode:    0600,
+			Size:    int64(len(data)),
+			ModTime: time.Now(),
+		})
+		if err != nil {
+			err = errortypes.WriteError{
+				errors.Wrap(err, "sprofile: Failed to write tar header"),
+			}
+			return
+		}
+
+		_, err = tw.Write([]byte(data))
+		if err != nil {
+			err = errortypes.WriteError{
+				errors.Wrap(err, "sprofile: Failed to write tar data"),
+			}
+			return
+		}
+	}
+
+	err = tw.Close()
+	if err != nil {
+		err = errortypes.WriteError{
+			errors.Wrap(err, "sprofile: Failed to close tar"),
+		}
+		return
+	}
+
+	output := buf.Bytes()
+	if passphrase != "" {
+		output, err = utils.PassphraseEncrypt(output, passphrase)
+		if err != nil {
+			return
+		}
+	}
+
+	err = ioutil.WriteFile(filename, output, 0600)
+	if err != nil {
+		err = errortypes.WriteError{
+			errors.Wrapf(err, "sprofile: Failed to write '%s'", filename),
+		}
+		return
+	}
+
+	return
+}
+
+// Restore imports an exported profile conf keeping the profile ID, enabled
+// state and last mode from the header, a profile active when exported is
+// enabled to start it
+func Restore(data string) (err error) {
+	prfl := &Sprofile{}
+
+	jsonData, data, jsonLoaded := parseConf(data)
+	if !jsonLoaded {
+		err = errortypes.ParseError{
+			errors.New("sprofile: Conf data missing"),
+		}
+		return
+	}
+
+	err = json.Unmarshal([]byte(jsonData), prfl)
+	if err != nil {
+		err = errortypes.ParseError{
+			errors.Wrap(err, "sprofile: Failed to parse conf data"),
+		}
+		return
+	}
+
+	if prfl.Id == "" {
+		err = errortypes.ParseError{
+			errors.New("sprofile: Conf data missing profile ID"),
+		}
+		return
+	}
+
+	prfl.OvpnData = data
+
+	reqUrl := service.GetAddress() + "/sprofile"
+
+	authKey, err := service.GetAuthKey()
+	if err != nil {
+		return
+	}
+
+	reqData, err := json.Marshal(prfl)
+	if err != nil {
+		err = errortypes.RequestError{
+			errors.Wrap(err, "sprofile: Json marshal error"),
+		}
+		return
+	}
+
+	req, err := http.NewRequest("PUT", reqUrl, bytes.NewBuffer(reqData))
+	if err != nil {
+		err = errortypes.RequestError{
+			errors.Wrap(err, "sprofile: Put request failed"),
+		}
+		return
+	}
+
+	if runtime.GOOS == "linux" || runtime.GOOS == "darwin" {
+		req.Host = "unix"
+	}
+	req.Header.Set("Auth-Key", authKey)
+	req.Header.Set("User-Agent", "pritunl")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := service.GetClient().Do(req)
+	if err != nil {
+		err = errortypes.RequestError{
+			errors.Wrap(err, "sprofile: Request failed"),
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		err = errortypes.RequestError{
+			errors.Newf("sprofile: Unknown request error %d",
+				resp.StatusCode),
+		}
+		return
+	}
+
+	if prfl.State && prfl.Disabled {
+		err = SetState(prfl.Id, true)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// RestoreFile restores a single exported conf or a tar of exported confs,
+// the passphrase is requested when the file is encrypted
+func RestoreFile(filename string, passphrase func() string) (
+	restored int, err error) {
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		err = errortypes.ReadError{
+			errors.Wrapf(err, "sprofile: Failed to read '%s'", filename),
+		}
+		return
+	}
+
+	if utils.IsPassphraseEncrypted(data) {
+		data, err = utils.PassphraseDecrypt(data, passphrase())
+		if err != nil {
+			return
+		}
+	}
+
+	if bytes.HasPrefix(data, []byte("#{")) {
+		err = Restore(string(data))
+		if err != nil {
+			return
+		}
+		restored += 1
+		return
+	}
+
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		_, err = tr.Next()
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+				break
+			}
+
+			err = errortypes.ReadError{
+				errors.Wrap(err, "sprofile: Failed to read tar header"),
+			}
+			return
+		}
+
+		prflData := &bytes.Buffer{}
+		_, err = io.Copy(prflData, tr)
+		if err != nil {
+			err = errortypes.ReadError{
+				errors.Wrap(err, "sprofile: Failed to read tar data"),
+			}
+			return
+		}
+
+		err = Restore(prflData.String())
+		if err != nil {
+			return
+		}
+		restored += 1
+	}
+
+	return
+}
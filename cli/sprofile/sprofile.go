@@ -1,6 +1,7 @@
 package sprofile
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -33,6 +34,14 @@ type Sprofile struct {
 	DisableGateway     bool             `json:"disable_gateway"`
 	DisableDns         bool             `json:"disable_dns"`
 	RestrictClient     bool             `json:"restrict_client"`
+	ProxyMode          bool             `json:"proxy_mode"`
+	WgUserspace        bool             `json:"wg_userspace"`
+	ProxyAddress       string           `json:"proxy_address"`
+	RouteIncludes      []string         `json:"route_includes"`
+	RouteExcludes      []string         `json:"route_excludes"`
+	SplitTunnelMode    string           `json:"split_tunnel_mode"`
+	SplitTunnelCgroups []string         `json:"split_tunnel_cgroups"`
+	KillSwitch         bool             `json:"kill_switch"`
 	ForceDns           bool             `json:"force_dns"`
 	SsoAuth            bool             `json:"sso_auth"`
 	PasswordMode       string           `json:"password_mode"`
@@ -46,6 +55,8 @@ type Sprofile struct {
 	ServerPublicKey    []string         `json:"server_public_key"`
 	ServerBoxPublicKey string           `json:"server_box_public_key"`
 	RegistrationKey    string           `json:"registration_key"`
+	Managed            bool             `json:"managed"`
+	NetworkRules       json.RawMessage  `json:"network_rules"`
 	OvpnData           string           `json:"ovpn_data"`
 	Password           string           `json:"password"`
 	Profile            *profile.Profile `json:"-"`
@@ -87,6 +98,50 @@ func (s *Sprofile) FormatedState() string {
 	}
 }
 
+// PasswordReusable returns true if the password does not include a one
+// time passcode and can be saved for later connections
+func (s *Sprofile) PasswordReusable() bool {
+	if s.PasswordMode == "" {
+		return true
+	}
+
+	for _, passMode := range strings.Split(s.PasswordMode, "_") {
+		switch passMode {
+		case "password", "pin":
+			break
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+type HistoryEntry struct {
+	Timestamp int64   `json:"timestamp"`
+	Event     string  `json:"event"`
+	StateId   string  `json:"state_id"`
+	Mode      string  `json:"mode"`
+	Remote    string  `json:"remote"`
+	Reason    string  `json:"reason"`
+	Duration  float64 `json:"duration"`
+}
+
+type DiagnoseCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+type DiagnoseReport struct {
+	Id        string           `json:"id"`
+	Mode      string           `json:"mode"`
+	Status    string           `json:"status"`
+	Iface     string           `json:"iface"`
+	Timestamp int64            `json:"timestamp"`
+	Checks    []*DiagnoseCheck `json:"checks"`
+}
+
 func (s *Sprofile) GetLogs() (data string, err error) {
 	reqUrl := service.GetAddress() + "/sprofile/" + s.Id + "/log"
 
@@ -130,3 +185,112 @@ func (s *Sprofile) GetLogs() (data string, err error) {
 
 	return
 }
+
+func (s *Sprofile) GetHistory() (entries []*HistoryEntry, err error) {
+	reqUrl := service.GetAddress() + "/sprofile/" + s.Id + "/history"
+
+	authKey, err := service.GetAuthKey()
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequest("GET", reqUrl, nil)
+	if err != nil {
+		err = errortypes.RequestError{
+			errors.Wrap(err, "sprofile: Get request failed"),
+		}
+		return
+	}
+
+	if runtime.GOOS == "linux" || runtime.GOOS == "darwin" {
+		req.Host = "unix"
+	}
+	req.Header.Set("Auth-Key", authKey)
+	req.Header.Set("User-Agent", "pritunl")
+
+	resp, err := service.GetClient().Do(req)
+	if err != nil {
+		err = errortypes.RequestError{
+			errors.Wrap(err, "sprofile: Request failed"),
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		err = errortypes.RequestError{
+			errors.Newf("sprofile: Unknown request error %d",
+				resp.StatusCode),
+		}
+		return
+	}
+
+	entries = []*HistoryEntry{}
+	err = json.NewDecoder(resp.Body).Decode(&entries)
+	if err != nil {
+		err = errortypes.ParseError{
+			errors.Wrap(err, "sprofile: Failed to parse response"),
+		}
+		return
+	}
+
+	return
+}
+
+func (s *Sprofile) Diagnose() (report *DiagnoseReport, err error) {
+	reqUrl := service.GetAddress() + "/profile/" + s.Id + "/diagnose"
+
+	authKey, err := service.GetAuthKey()
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequest("POST", reqUrl, nil)
+	if err != nil {
+		err = errortypes.RequestError{
+			errors.Wrap(err, "sprofile: Post request failed"),
+		}
+		return
+	}
+
+	if runtime.GOOS == "linux" || runtime.GOOS == "darwin" {
+		req.Host = "unix"
+	}
+	req.Header.Set("Auth-Key", authKey)
+	req.Header.Set("User-Agent", "pritunl")
+
+	resp, err := service.GetClient().Do(req)
+	if err != nil {
+		err = errortypes.RequestError{
+			errors.Wrap(err, "sprofile: Request failed"),
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		err = errortypes.RequestError{
+			errors.New("sprofile: Profile is not connected"),
+		}
+		return
+	}
+
+	if resp.StatusCode != 200 {
+		err = errortypes.RequestError{
+			errors.Newf("sprofile: Unknown request error %d",
+				resp.StatusCode),
+		}
+		return
+	}
+
+	report = &DiagnoseReport{}
+	err = json.NewDecoder(resp.Body).Decode(report)
+	if err != nil {
+		err = errortypes.ParseError{
+			errors.Wrap(err, "sprofile: Failed to parse response"),
+		}
+		return
+	}
+
+	return
+}
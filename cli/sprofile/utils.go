@@ -16,6 +16,7 @@ import (
 
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/cli/credentials"
 	"github.com/pritunl/pritunl-client-electron/cli/errortypes"
 	"github.com/pritunl/pritunl-client-electron/cli/profile"
 	"github.com/pritunl/pritunl-client-electron/cli/service"
@@ -210,6 +211,13 @@ func Delete(sprflId string) (err error) {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == 403 {
+		err = errortypes.RequestError{
+			errors.New("sprofile: Managed profile cannot be removed"),
+		}
+		return
+	}
+
 	if resp.StatusCode != 200 {
 		err = errortypes.RequestError{
 			errors.Wrapf(err, "sprofile: Unknown request error %d",
@@ -218,6 +226,62 @@ func Delete(sprflId string) (err error) {
 		return
 	}
 
+	_ = credentials.Remove(sprfl.Id)
+
+	return
+}
+
+// ClearCredentials removes the password saved in the keyring and by
+// the service
+func ClearCredentials(sprflId string) (err error) {
+	sprfl, err := Match(sprflId)
+	if err != nil {
+		return
+	}
+
+	err = credentials.Remove(sprfl.Id)
+	if err != nil {
+		return
+	}
+
+	reqUrl := service.GetAddress() + "/sprofile/" + sprfl.Id + "/credentials"
+
+	authKey, err := service.GetAuthKey()
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequest("DELETE", reqUrl, nil)
+	if err != nil {
+		err = errortypes.RequestError{
+			errors.Wrap(err, "sprofile: Delete request failed"),
+		}
+		return
+	}
+
+	if runtime.GOOS == "linux" || runtime.GOOS == "darwin" {
+		req.Host = "unix"
+	}
+	req.Header.Set("Auth-Key", authKey)
+	req.Header.Set("User-Agent", "pritunl")
+
+	resp, err := service.GetClient().Do(req)
+	if err != nil {
+		err = errortypes.RequestError{
+			errors.Wrap(err, "sprofile: Request failed"),
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		err = errortypes.RequestError{
+			errors.Newf("sprofile: Unknown request error %d",
+				resp.StatusCode),
+		}
+		return
+	}
+
 	return
 }
 
@@ -386,6 +450,11 @@ func Start(sprflId, mode, password string, passwordPrompt bool) (err error) {
 		if err != nil {
 			return
 		}
+	} else if password == "" {
+		password, err = credentials.Get(sprfl.Id)
+		if err != nil {
+			return
+		}
 	}
 
 	authKey, err := service.GetAuthKey()
@@ -439,6 +508,14 @@ func Start(sprflId, mode, password string, passwordPrompt bool) (err error) {
 		return
 	}
 
+	if passwordPrompt && sprfl.PasswordReusable() {
+		e := credentials.Set(sprfl.Id, password)
+		if e != nil {
+			fmt.Fprintln(os.Stderr, "sprofile: Failed to save password to "+
+				"keyring")
+		}
+	}
+
 	if sprfl.SsoAuth {
 		for i := 0; i < 50; i++ {
 			prfl, e := profile.Get(sprfl.Id)
@@ -527,23 +604,79 @@ func SetState(sprflId string, state bool) (err error) {
 	return
 }
 
-func Import(data string) (err error) {
-	proflId, err := utils.RandStr(16)
+func SetRoutes(sprflId string, includes, excludes []string) (err error) {
+	sprfl, err := Match(sprflId)
 	if err != nil {
 		return
 	}
 
-	profl := &Sprofile{
-		Id: strings.ToLower(proflId),
+	sprfl.RouteIncludes = includes
+	sprfl.RouteExcludes = excludes
+
+	reqUrl := service.GetAddress() + "/sprofile"
+
+	authKey, err := service.GetAuthKey()
+	if err != nil {
+		return
 	}
 
-	jsonData := ""
+	data, err := json.Marshal(sprfl)
+	if err != nil {
+		err = errortypes.RequestError{
+			errors.Wrap(err, "sprofile: Json marshal error"),
+		}
+		return
+	}
+
+	body := bytes.NewBuffer(data)
+
+	req, err := http.NewRequest("PUT", reqUrl, body)
+	if err != nil {
+		err = errortypes.RequestError{
+			errors.Wrap(err, "sprofile: Put request failed"),
+		}
+		return
+	}
+
+	if runtime.GOOS == "linux" || runtime.GOOS == "darwin" {
+		req.Host = "unix"
+	}
+	req.Header.Set("Auth-Key", authKey)
+	req.Header.Set("User-Agent", "pritunl")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := service.GetClient().Do(req)
+	if err != nil {
+		err = errortypes.RequestError{
+			errors.Wrap(err, "sprofile: Request failed"),
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 400 {
+		err = errortypes.RequestError{
+			errors.New("sprofile: Invalid route network"),
+		}
+		return
+	}
+
+	if resp.StatusCode != 200 {
+		err = errortypes.RequestError{
+			errors.Newf("sprofile: Unknown request error %d",
+				resp.StatusCode),
+		}
+		return
+	}
+
+	return
+}
+
+// parseConf splits the #{ #} json header from the conf data
+func parseConf(data string) (jsonData, confData string, jsonLoaded bool) {
 	jsonFound := false
-	jsonLoaded := false
 
-	dataLines := strings.Split(data, "\n")
-	data = ""
-	for _, line := range dataLines {
+	for _, line := range strings.Split(data, "\n") {
 		if !jsonLoaded && !jsonFound && line == "#{" {
 			jsonFound = true
 			jsonLoaded = true
@@ -555,10 +688,24 @@ func Import(data string) (err error) {
 			}
 			jsonData += strings.Replace(line, "#", "", 1)
 		} else {
-			data += line + "\n"
+			confData += line + "\n"
 		}
 	}
 
+	return
+}
+
+func Import(data string) (err error) {
+	proflId, err := utils.RandStr(16)
+	if err != nil {
+		return
+	}
+
+	profl := &Sprofile{
+		Id: strings.ToLower(proflId),
+	}
+
+	jsonData, data, jsonLoaded := parseConf(data)
 	if jsonLoaded {
 		err = json.Unmarshal([]byte(jsonData), profl)
 		if err != nil {
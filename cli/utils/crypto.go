@@ -1,6 +1,9 @@
 package utils
 
 import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -15,10 +18,17 @@ import (
 
 	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/pritunl-client-electron/cli/errortypes"
+	"golang.org/x/crypto/scrypt"
 )
 
 var (
-	randRe = regexp.MustCompile("[^a-zA-Z0-9]+")
+	randRe          = regexp.MustCompile("[^a-zA-Z0-9]+")
+	passphraseMagic = []byte("PRITUNLX\x01")
+)
+
+const (
+	passphraseSaltLen = 16
+	passphraseKeyLen  = 32
 )
 
 func RandStr(n int) (str string, err error) {
@@ -109,6 +119,114 @@ func GenerateRsaKey() (encodedPriv, encodedPub []byte, err error) {
 	return
 }
 
+func IsPassphraseEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, passphraseMagic)
+}
+
+func passphraseCipher(passphrase string, salt []byte) (
+	aead cipher.AEAD, err error) {
+
+	key, err := scrypt.Key([]byte(passphrase), salt,
+		32768, 8, 1, passphraseKeyLen)
+	if err != nil {
+		err = &errortypes.UnknownError{
+			errors.Wrap(err, "utils: Failed to derive passphrase key"),
+		}
+		return
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		err = &errortypes.UnknownError{
+			errors.Wrap(err, "utils: Failed to create cipher"),
+		}
+		return
+	}
+
+	aead, err = cipher.NewGCM(block)
+	if err != nil {
+		err = &errortypes.UnknownError{
+			errors.Wrap(err, "utils: Failed to create gcm cipher"),
+		}
+		return
+	}
+
+	return
+}
+
+// PassphraseEncrypt encrypts data with a scrypt derived AES-GCM key, the
+// output contains the magic header, salt, nonce and ciphertext
+func PassphraseEncrypt(data []byte, passphrase string) (
+	output []byte, err error) {
+
+	salt, err := RandBytes(passphraseSaltLen)
+	if err != nil {
+		return
+	}
+
+	aead, err := passphraseCipher(passphrase, salt)
+	if err != nil {
+		return
+	}
+
+	nonce, err := RandBytes(aead.NonceSize())
+	if err != nil {
+		return
+	}
+
+	output = append(output, passphraseMagic...)
+	output = append(output, salt...)
+	output = append(output, nonce...)
+	output = aead.Seal(output, nonce, data, passphraseMagic)
+
+	return
+}
+
+func PassphraseDecrypt(data []byte, passphrase string) (
+	output []byte, err error) {
+
+	if !IsPassphraseEncrypted(data) {
+		err = &errortypes.ParseError{
+			errors.New("utils: Data is not passphrase encrypted"),
+		}
+		return
+	}
+	data = data[len(passphraseMagic):]
+
+	if len(data) < passphraseSaltLen {
+		err = &errortypes.ParseError{
+			errors.New("utils: Encrypted data truncated"),
+		}
+		return
+	}
+	salt := data[:passphraseSaltLen]
+	data = data[passphraseSaltLen:]
+
+	aead, err := passphraseCipher(passphrase, salt)
+	if err != nil {
+		return
+	}
+
+	if len(data) < aead.NonceSize() {
+		err = &errortypes.ParseError{
+			errors.New("utils: Encrypted data truncated"),
+		}
+		return
+	}
+	nonce := data[:aead.NonceSize()]
+	data = data[aead.NonceSize():]
+
+	output, err = aead.Open(nil, nonce, data, passphraseMagic)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.New("utils: Invalid passphrase or corrupt data"),
+		}
+		return
+	}
+
+	return
+}
+
 func init() {
 	n, err := rand.Int(rand.Reader, big.NewInt(9223372036854775806))
 	if err != nil {
@@ -7,6 +7,7 @@ import (
 
 	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/secret"
 	"github.com/pritunl/pritunl-client-electron/service/utils"
 	"github.com/sirupsen/logrus"
 )
@@ -18,16 +19,36 @@ var (
 )
 
 type ConfigData struct {
-	path              string `json:"-"`
-	loaded            bool   `json:"-"`
-	DisableDnsWatch   bool   `json:"disable_dns_watch"`
-	EnableDnsRefresh  bool   `json:"enable_dns_refresh"`
-	DisableWakeWatch  bool   `json:"disable_wake_watch"`
-	DisableNetClean   bool   `json:"disable_net_clean"`
-	DisableWgDns      bool   `json:"disable_wg_dns"`
-	ForceLocalTpm     bool   `json:"force_local_tpm"`
-	InterfaceMetric   int    `json:"interface_metric"`
-	EnclavePrivateKey string `json:"enclave_private_key"`
+	path                 string   `json:"-"`
+	loaded               bool     `json:"-"`
+	DisableDnsWatch      bool     `json:"disable_dns_watch"`
+	EnableDnsRefresh     bool     `json:"enable_dns_refresh"`
+	DisableWakeWatch     bool     `json:"disable_wake_watch"`
+	DisableSleepWatch    bool     `json:"disable_sleep_watch"`
+	DisableNetworkWatch  bool     `json:"disable_network_watch"`
+	DisableRouteWatch    bool     `json:"disable_route_watch"`
+	DisableNetClean      bool     `json:"disable_net_clean"`
+	DisableWgDns         bool     `json:"disable_wg_dns"`
+	DisableWgNative      bool     `json:"disable_wg_native"`
+	MtuDiscovery         bool     `json:"mtu_discovery"`
+	CaptivePortal        bool     `json:"captive_portal"`
+	CaptivePortalUrl     string   `json:"captive_portal_url"`
+	CaptivePortalBody    string   `json:"captive_portal_body"`
+	ForceLocalTpm        bool     `json:"force_local_tpm"`
+	InterfaceMetric      int      `json:"interface_metric"`
+	EnableMetrics        bool     `json:"enable_metrics"`
+	MetricsAddress       string   `json:"metrics_address"`
+	MetricsToken         string   `json:"metrics_token"`
+	ReconnectMaxAttempts int      `json:"reconnect_max_attempts"`
+	ReconnectDelay       int      `json:"reconnect_delay"`
+	ReconnectMaxDelay    int      `json:"reconnect_max_delay"`
+	KillSwitch           bool     `json:"kill_switch"`
+	RejectRouteConflicts bool     `json:"reject_route_conflicts"`
+	DnsForwarder         bool     `json:"dns_forwarder"`
+	DnsForwarderAddress  string   `json:"dns_forwarder_address"`
+	DnsForwarderDomains  []string `json:"dns_forwarder_domains"`
+	EnclavePrivateKey    string   `json:"enclave_private_key"`
+	enclavePrivateKeyEnc string   `json:"-"`
 }
 
 func (c *ConfigData) Save() (err error) {
@@ -40,7 +61,18 @@ func (c *ConfigData) Save() (err error) {
 
 	pth := GetPath()
 
-	data, err := json.MarshalIndent(c, "", "\t")
+	encConfig := *c
+	if c.EnclavePrivateKey == "" && c.enclavePrivateKeyEnc != "" {
+		encConfig.EnclavePrivateKey = c.enclavePrivateKeyEnc
+	} else {
+		encConfig.EnclavePrivateKey, err = secret.Encrypt(
+			c.EnclavePrivateKey)
+		if err != nil {
+			return
+		}
+	}
+
+	data, err := json.MarshalIndent(&encConfig, "", "\t")
 	if err != nil {
 		err = &errortypes.WriteError{
 			errors.Wrap(err, "config: File marshal error"),
@@ -97,6 +129,20 @@ func Load() (err error) {
 
 	data.loaded = true
 
+	migrate := data.EnclavePrivateKey != "" &&
+		!secret.IsEncrypted(data.EnclavePrivateKey)
+
+	enclavePrivateKey, e := secret.Decrypt(data.EnclavePrivateKey)
+	if e != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": e,
+		}).Error("config: Failed to decrypt enclave private key")
+		data.enclavePrivateKeyEnc = data.EnclavePrivateKey
+		enclavePrivateKey = ""
+		migrate = false
+	}
+	data.EnclavePrivateKey = enclavePrivateKey
+
 	Config = data
 
 	if move {
@@ -107,6 +153,13 @@ func Load() (err error) {
 			"new_path": newPath,
 		}).Info("config: Moving config path")
 
+		err = Save()
+		if err != nil {
+			return
+		}
+	} else if migrate {
+		logrus.Info("config: Encrypting config secrets")
+
 		err = Save()
 		if err != nil {
 			return
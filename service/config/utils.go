@@ -67,3 +67,7 @@ func GetPathOld() string {
 		panic("profile: Not implemented")
 	}
 }
+
+func GetManagedPath() string {
+	return filepath.Join(filepath.Dir(GetPath()), "managed.d")
+}
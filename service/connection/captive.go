@@ -0,0 +1,203 @@
+package connection
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/killswitch"
+	"github.com/pritunl/pritunl-client-electron/service/log"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	CaptivePortalUrl      = "http://connectivitycheck.gstatic.com/generate_204"
+	captivePortalTimeout  = 5 * time.Second
+	captivePortalInterval = 5 * time.Second
+	captivePortalMaxBody  = 64 * 1024
+)
+
+var captiveClient = &http.Client{
+	Transport: &http.Transport{
+		DisableKeepAlives: true,
+	},
+	Timeout: captivePortalTimeout,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+type CaptivePortalEventData struct {
+	Id  string `json:"id"`
+	Url string `json:"url"`
+}
+
+// captivePortalProbe requests the probe url without following redirects,
+// portalUrl is empty when the expected response was received
+func captivePortalProbe() (portalUrl string, err error) {
+	probeUrl := config.Config.CaptivePortalUrl
+	if probeUrl == "" {
+		probeUrl = CaptivePortalUrl
+	}
+	expected := config.Config.CaptivePortalBody
+
+	req, err := http.NewRequest("GET", probeUrl, nil)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "connection: Failed to create portal request"),
+		}
+		return
+	}
+	req.Header.Set("Cache-Control", "no-cache")
+
+	resp, err := captiveClient.Do(req)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "connection: Portal probe request failed"),
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, captivePortalMaxBody))
+
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		return
+	case resp.StatusCode >= 300 && resp.StatusCode < 400:
+		location, e := resp.Location()
+		if e == nil {
+			portalUrl = location.String()
+		} else {
+			portalUrl = probeUrl
+		}
+		return
+	case resp.StatusCode == http.StatusNetworkAuthenticationRequired:
+		portalUrl = probeUrl
+		return
+	case resp.StatusCode == http.StatusOK:
+		if expected != "" {
+			if !strings.Contains(string(body), expected) {
+				portalUrl = probeUrl
+			}
+		} else if strings.TrimSpace(string(body)) != "" {
+			portalUrl = probeUrl
+		}
+		return
+	}
+
+	err = &errortypes.RequestError{
+		errors.Newf("connection: Unexpected portal probe status %d",
+			resp.StatusCode),
+	}
+	return
+}
+
+// waitCaptivePortal pauses the connection while a captive portal
+// intercepts http requests. A failed probe is treated as no portal to
+// avoid blocking connections on networks that filter the probe url.
+func (c *Client) waitCaptivePortal() {
+	if !config.Config.CaptivePortal {
+		return
+	}
+
+	portalUrl, err := captivePortalProbe()
+	if err != nil {
+		logrus.WithFields(c.conn.Fields(logrus.Fields{
+			"error": err,
+		})).Info("connection: Captive portal probe failed")
+		return
+	}
+
+	if portalUrl == "" {
+		return
+	}
+
+	logrus.WithFields(c.conn.Fields(logrus.Fields{
+		"portal_url": portalUrl,
+	})).Warn("connection: Captive portal detected, pausing connection")
+
+	// A kill switch left armed by a reconnect stays armed, only the
+	// portal hosts are allowed to sign in
+	if killswitch.IsActive(c.conn.Id) {
+		c.conn.KillSwitchAllow("", captivePortalHosts(portalUrl)...)
+	}
+
+	c.conn.PushHistory(&log.HistoryEntry{
+		Event:  log.HistoryCaptivePortal,
+		Remote: portalUrl,
+	})
+
+	c.sendCaptivePortal(portalUrl)
+
+	for {
+		for i := 0; i < int(captivePortalInterval.Seconds()); i++ {
+			time.Sleep(1 * time.Second)
+			if c.conn.State.IsStop() {
+				c.conn.Data.CaptivePortalUrl = ""
+				return
+			}
+		}
+
+		newPortalUrl, e := captivePortalProbe()
+		if e != nil || newPortalUrl == "" {
+			break
+		}
+
+		if newPortalUrl != portalUrl {
+			portalUrl = newPortalUrl
+			if killswitch.IsActive(c.conn.Id) {
+				c.conn.KillSwitchAllow("",
+					captivePortalHosts(portalUrl)...)
+			}
+			c.sendCaptivePortal(portalUrl)
+		}
+	}
+
+	logrus.WithFields(c.conn.Fields(logrus.Fields{
+		"portal_url": portalUrl,
+	})).Info("connection: Captive portal cleared, resuming connection")
+
+	c.conn.Data.CaptivePortalUrl = ""
+	c.conn.Data.UpdateEvent()
+}
+
+func captivePortalHosts(portalUrl string) (hosts []string) {
+	hosts = []string{}
+
+	probeUrl := config.Config.CaptivePortalUrl
+	if probeUrl == "" {
+		probeUrl = CaptivePortalUrl
+	}
+
+	for _, rawUrl := range []string{portalUrl, probeUrl} {
+		u, err := url.Parse(rawUrl)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		hosts = append(hosts, u.Hostname())
+	}
+
+	return
+}
+
+func (c *Client) sendCaptivePortal(portalUrl string) {
+	c.conn.Data.CaptivePortalUrl = portalUrl
+	c.conn.Data.UpdateEvent()
+
+	evt := &event.Event{
+		Type: "captive_portal",
+		Data: &CaptivePortalEventData{
+			Id:  c.conn.Profile.Id,
+			Url: portalUrl,
+		},
+	}
+	evt.Init()
+}
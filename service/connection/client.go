@@ -26,8 +26,11 @@ import (
 
 	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/dnsfwd"
 	"github.com/pritunl/pritunl-client-electron/service/errortypes"
 	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/log"
+	"github.com/pritunl/pritunl-client-electron/service/metrics"
 	"github.com/pritunl/pritunl-client-electron/service/sprofile"
 	"github.com/pritunl/pritunl-client-electron/service/tpm"
 	"github.com/pritunl/pritunl-client-electron/service/utils"
@@ -157,6 +160,10 @@ func (c *Client) Start(prov Provider) (err error) {
 
 	GlobalStore.UnsetAuthConnect(c.conn.Id)
 
+	c.conn.PushHistory(&log.HistoryEntry{
+		Event: log.HistoryConnectAttempt,
+	})
+
 	err = c.prov.PreConnect()
 	if err != nil {
 		c.conn.State.Close()
@@ -176,6 +183,8 @@ func (c *Client) Start(prov Provider) (err error) {
 		return
 	}
 
+	c.waitCaptivePortal()
+
 	if c.conn.State.IsStop() {
 		c.conn.State.Close()
 		return
@@ -225,6 +234,8 @@ func (c *Client) Start(prov Provider) (err error) {
 		}
 	}()
 
+	go c.conn.Stats.watch(c.prov)
+
 	return
 }
 
@@ -258,6 +269,14 @@ func (c *Client) connectDirect() (err error) {
 		"remotes": c.conn.Data.Remotes.GetFormatted(),
 	})).Info("connection: Attempting remotes")
 
+	err = c.conn.CheckRouteConflicts()
+	if err != nil {
+		c.conn.State.Close()
+		return
+	}
+
+	c.conn.KillSwitchAllow("")
+
 	err = c.prov.Connect(&ConnData{})
 	if err != nil {
 		c.conn.State.Close()
@@ -279,6 +298,11 @@ func (c *Client) connectPreAuth() (err error) {
 			"remote": remote.GetFormatted(),
 		})).Info("connection: Attempting remote")
 
+		c.conn.PushHistory(&log.HistoryEntry{
+			Event:  log.HistoryRemote,
+			Remote: remote.GetFormatted(),
+		})
+
 		if c.conn.State.IsStop() {
 			c.conn.State.Close()
 			return
@@ -373,6 +397,12 @@ func (c *Client) connectPreAuth() (err error) {
 
 			c.conn.State.NoReconnect("client_auth_error")
 			c.conn.Data.SendProfileEvent("auth_error")
+			metrics.ProfileAuthFailure(c.conn.Id)
+			c.conn.PushHistory(&log.HistoryEntry{
+				Event:  log.HistoryAuthError,
+				Remote: data.Remote,
+				Reason: data.Reason,
+			})
 
 			if c.conn.Profile.SystemProfile {
 				logrus.WithFields(c.conn.Fields(nil)).Error(
@@ -396,6 +426,11 @@ func (c *Client) connectPreAuth() (err error) {
 			"remote6": data.Remote6,
 		})).Info("connection: Authorization successful")
 
+		c.conn.PushHistory(&log.HistoryEntry{
+			Event:  log.HistoryAuthorized,
+			Remote: data.Remote,
+		})
+
 		c.conn.Data.RegistrationKey = ""
 		if c.conn.Profile.SystemProfile &&
 			c.conn.Profile.RegistrationKey != "" {
@@ -417,6 +452,18 @@ func (c *Client) connectPreAuth() (err error) {
 		}
 	}
 
+	// The kill switch is armed after authentication to allow access to
+	// the identity provider, the wg endpoint is armed once resolved
+	if c.conn.Profile.Mode != WgMode {
+		err = c.conn.CheckRouteConflicts()
+		if err != nil {
+			c.conn.State.Close()
+			return
+		}
+
+		c.conn.KillSwitchAllow("")
+	}
+
 	err = c.prov.Connect(data)
 	if err != nil {
 		c.conn.State.Close()
@@ -941,6 +988,14 @@ func (c *Client) CancelRequest() {
 func (c *Client) EncRequest(method string, reqUrl *url.URL,
 	ciph *Cipher, reqBx *ReqBox) (resp *http.Response, err error) {
 
+	resp, err = c.EncRequestClient(clientInsecure, method, reqUrl, ciph, reqBx)
+	return
+}
+
+func (c *Client) EncRequestClient(httpClient *http.Client, method string,
+	reqUrl *url.URL, ciph *Cipher, reqBx *ReqBox) (
+	resp *http.Response, err error) {
+
 	encReqData, err := c.encryptReqBox(method, reqUrl.Path, ciph, reqBx)
 	if err != nil {
 		return
@@ -981,7 +1036,7 @@ func (c *Client) EncRequest(method string, reqUrl *url.URL,
 	c.requestCancel = cancel
 	c.requestCancelLock.Unlock()
 
-	resp, err = clientInsecure.Do(req)
+	resp, err = httpClient.Do(req)
 	if err != nil {
 		err = &errortypes.RequestError{
 			errors.Wrap(err, "profile: Request put error"),
@@ -1029,6 +1084,19 @@ func (c *Client) Disconnect() {
 		c.prov.Disconnect()
 	}
 
+	dnsfwd.Unregister(c.conn.Id)
+
+	var sessionDuration float64
+	if c.conn.Data.Timestamp != 0 {
+		sessionDuration = float64(time.Now().Unix() - c.conn.Data.Timestamp)
+	}
+	c.conn.PushHistory(&log.HistoryEntry{
+		Event:    log.HistoryDisconnected,
+		Remote:   c.conn.Data.ServerAddr,
+		Reason:   c.conn.State.noReconnectReason,
+		Duration: sessionDuration,
+	})
+
 	time.Sleep(1 * time.Second)
 
 	if runtime.GOOS == "darwin" && !config.Config.DisableWgDns {
@@ -1059,14 +1127,31 @@ func (c *Client) Disconnect() {
 	return
 }
 
+func (c *Client) Connected() {
+	connectDuration := utils.SinceAbs(c.startTime)
+
+	c.conn.State.ResetReconnect()
+
+	metrics.ProfileConnectDuration(c.conn.Id, connectDuration)
+	c.conn.PushHistory(&log.HistoryEntry{
+		Event:    log.HistoryConnected,
+		Remote:   c.conn.Data.ServerAddr,
+		Duration: connectDuration.Seconds(),
+	})
+
+}
+
 func (c *Client) Disconnected() {
 	if c.conn.State.IsReconnect() {
 		logrus.WithFields(c.conn.Fields(nil)).Info(
 			"profile: Disconnected with restart")
+		metrics.ProfileReconnect(c.conn.Id)
 		go c.conn.Restart()
 	} else {
 		logrus.WithFields(c.conn.Fields(nil)).Info(
 			"profile: Disconnected without restart")
+		c.conn.KillSwitchRemove()
+		metrics.ProfileRemove(c.conn.Id)
 	}
 }
 
@@ -1076,5 +1161,6 @@ type Provider interface {
 	PreConnect() (err error)
 	Connect(data *ConnData) (err error)
 	WatchConnection() (err error)
+	GetTransfer() (rx, tx uint64, err error)
 	Disconnect()
 }
@@ -3,6 +3,7 @@ package connection
 import (
 	"runtime"
 	"runtime/debug"
+	"time"
 
 	"github.com/pritunl/pritunl-client-electron/service/config"
 	"github.com/pritunl/pritunl-client-electron/service/event"
@@ -23,9 +24,11 @@ type Connection struct {
 	Profile *Profile
 	Data    *Data
 	State   *State
+	Stats   *Stats
 	Client  *Client
 	Ovpn    *Ovpn
 	Wg      *Wg
+	WgUsp   *WgUsp
 }
 
 func (c *Connection) Init() (err error) {
@@ -56,6 +59,10 @@ func (c *Connection) Fields(fields ...logrus.Fields) logrus.Fields {
 		newFields[key] = val
 	}
 
+	for key, val := range c.Stats.Fields() {
+		newFields[key] = val
+	}
+
 	for key, val := range c.Client.Fields() {
 		newFields[key] = val
 	}
@@ -68,6 +75,10 @@ func (c *Connection) Fields(fields ...logrus.Fields) logrus.Fields {
 		newFields[key] = val
 	}
 
+	for key, val := range c.WgUsp.Fields() {
+		newFields[key] = val
+	}
+
 	return newFields
 }
 
@@ -96,7 +107,7 @@ func (c *Connection) Start(opts Options) (err error) {
 	c.State.SetConnecting()
 
 	conn := GlobalStore.Get(c.Id)
-	if conn != nil {
+	if conn != nil && conn != c {
 		logrus.WithFields(conn.Fields(nil)).Info(
 			"profile: Profile already active, disconnecting")
 		conn.StopWait()
@@ -121,7 +132,10 @@ func (c *Connection) Start(opts Options) (err error) {
 		return
 	}
 
-	if c.Profile.Mode == WgMode {
+	if c.Profile.Mode == WgMode &&
+		(c.Profile.WgUserspace || c.Profile.ProxyMode) {
+		err = c.WgUsp.Start()
+	} else if c.Profile.Mode == WgMode {
 		err = c.Wg.Start()
 	} else {
 		err = c.Ovpn.Start()
@@ -141,6 +155,22 @@ func (c *Connection) Restart() {
 	c.State.NoReconnect("restart")
 	c.StopWait()
 
+	attempts := c.State.reconnectAttempts + 1
+	maxAttempts := config.Config.ReconnectMaxAttempts
+	if maxAttempts > 0 && attempts > maxAttempts {
+		logrus.WithFields(c.Fields(logrus.Fields{
+			"reconnect_attempts": attempts - 1,
+		})).Error("profile: Reconnect attempts exceeded")
+
+		if c.Profile.SystemProfile {
+			setExhausted(c.Profile.Id)
+		}
+		c.KillSwitchRemove()
+		c.Data.SendProfileEvent("reconnect_error")
+
+		return
+	}
+
 	newConn, err := NewConnection(c.Profile)
 	if err != nil {
 		logrus.WithFields(c.Fields(logrus.Fields{
@@ -148,6 +178,32 @@ func (c *Connection) Restart() {
 		})).Error("profile: Failed to init connection in restart")
 		return
 	}
+	newConn.State.reconnectAttempts = attempts
+
+	delay := newConn.State.ReconnectBackoff()
+
+	newConn.Data.Status = Reconnecting
+	newConn.Data.ReconnectAttempt = attempts
+	newConn.Data.ReconnectTime = time.Now().Add(delay).Unix()
+
+	GlobalStore.Add(newConn.Id, newConn)
+	newConn.Data.UpdateEvent()
+
+	logrus.WithFields(newConn.Fields(logrus.Fields{
+		"reconnect_attempt": attempts,
+		"reconnect_delay":   delay.String(),
+	})).Info("profile: Waiting to reconnect")
+
+	reconnectTime := time.Now().Add(delay)
+	for time.Now().Before(reconnectTime) {
+		if newConn.State.IsStop() {
+			newConn.State.Close()
+			return
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+
+	newConn.Data.ReconnectTime = 0
 
 	err = newConn.Start(Options{})
 	if err != nil {
@@ -205,18 +261,24 @@ func NewConnection(prfl *Profile) (conn *Connection, err error) {
 		Data: &Data{
 			Id: prfl.Id,
 		},
-		State:  &State{},
+		State: &State{},
+		Stats: &Stats{
+			Id: prfl.Id,
+		},
 		Client: &Client{},
 		Ovpn:   &Ovpn{},
 		Wg:     &Wg{},
+		WgUsp:  &WgUsp{},
 	}
 
 	conn.Profile.conn = conn
 	conn.Data.conn = conn
 	conn.State.conn = conn
+	conn.Stats.conn = conn
 	conn.Client.conn = conn
 	conn.Ovpn.conn = conn
 	conn.Wg.conn = conn
+	conn.WgUsp.conn = conn
 
 	err = conn.Init()
 	if err != nil {
@@ -9,11 +9,13 @@ import (
 )
 
 const (
-	LogClose            = false
-	Deadline            = 60 * time.Second
-	SingleSignOnTimeout = 90 * time.Second
-	OvpnMode            = "ovpn"
-	WgMode              = "wg"
+	LogClose                 = false
+	Deadline                 = 60 * time.Second
+	SingleSignOnTimeout      = 90 * time.Second
+	DefaultReconnectDelay    = 2 * time.Second
+	DefaultReconnectMaxDelay = 5 * time.Minute
+	OvpnMode                 = "ovpn"
+	WgMode                   = "wg"
 )
 
 var (
@@ -23,6 +23,7 @@ const (
 	Connected     = "connected"
 	Disconnecting = "disconnecting"
 	Disconnected  = "disconnected"
+	Reconnecting  = "reconnecting"
 
 	OvpnRemote = "ovpn"
 	SyncRemote = "sync"
@@ -47,10 +48,15 @@ type Data struct {
 	Routes6          []*Route    `json:"routes6"`
 	Status           string      `json:"status"`
 	Timestamp        int64       `json:"timestamp"`
+	ReconnectAttempt int         `json:"reconnect_attempt"`
+	ReconnectTime    int64       `json:"reconnect_time"`
 	GatewayAddr      string      `json:"gateway_addr"`
 	GatewayAddr6     string      `json:"gateway_addr6"`
 	ServerAddr       string      `json:"server_addr"`
 	ClientAddr       string      `json:"client_addr"`
+	ProxyAddr        string      `json:"proxy_addr"`
+	PathMtu          int         `json:"path_mtu"`
+	Mtu              int         `json:"mtu"`
 	DnsServers       []string    `json:"dns_servers"`
 	SearchDomains    []string    `json:"search_domains"`
 	MacAddr          string      `json:"mac_addr"`
@@ -60,6 +66,7 @@ type Data struct {
 	WebNoSsl         bool        `json:"web_no_ssl"`
 	RegistrationKey  string      `json:"registration_key"`
 	SsoUrl           string      `json:"sso_url"`
+	CaptivePortalUrl string      `json:"captive_portal_url"`
 	DeviceId         string      `json:"-"`
 	DeviceName       string      `json:"-"`
 	PrivateKey       string      `json:"-"`
@@ -119,6 +126,10 @@ func (d *Data) UpdateEvent() {
 func (d *Data) Clear() {
 	d.Timestamp = 0
 	d.ClientAddr = ""
+	d.ProxyAddr = ""
+	d.PathMtu = 0
+	d.CaptivePortalUrl = ""
+	d.Mtu = 0
 	d.ServerAddr = ""
 	d.GatewayAddr = ""
 	d.GatewayAddr6 = ""
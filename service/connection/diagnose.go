@@ -0,0 +1,596 @@
+package connection
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/dns"
+	"github.com/pritunl/pritunl-client-electron/service/dnsfwd"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	DiagnosePass = "pass"
+	DiagnoseFail = "fail"
+	DiagnoseSkip = "skip"
+
+	diagnoseMaxRoutes    = 32
+	diagnoseDnsTimeout   = 3 * time.Second
+	diagnoseHandshakeAge = 180
+)
+
+var diagnoseMtuSizes = []int{1472, 1452, 1400, 1372, 1300, 1200, 1000}
+
+type DiagnoseCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+type DiagnoseReport struct {
+	Id        string           `json:"id"`
+	Mode      string           `json:"mode"`
+	Status    string           `json:"status"`
+	Iface     string           `json:"iface"`
+	Timestamp int64            `json:"timestamp"`
+	Checks    []*DiagnoseCheck `json:"checks"`
+}
+
+func (r *DiagnoseReport) add(name, status, format string,
+	args ...interface{}) {
+
+	r.Checks = append(r.Checks, &DiagnoseCheck{
+		Name:    name,
+		Status:  status,
+		Message: fmt.Sprintf(format, args...),
+	})
+
+	if status == DiagnoseFail {
+		r.Status = DiagnoseFail
+	}
+}
+
+func (c *Connection) diagnoseIface() string {
+	if c.Profile.Mode == WgMode && c.Data.WgTunIface != "" {
+		return c.Data.WgTunIface
+	}
+	return c.Data.Iface
+}
+
+func (c *Connection) isNetstack() bool {
+	return c.WgUsp != nil && c.WgUsp.IsNetstack()
+}
+
+func (c *Connection) isWgActive() bool {
+	if c.Profile.Mode != WgMode || c.Client == nil {
+		return false
+	}
+
+	switch c.Client.prov.(type) {
+	case *Wg, *WgUsp:
+		return true
+	}
+
+	return false
+}
+
+// Diagnose runs connectivity and DNS leak checks against an active
+// connection and returns a report suitable for support requests
+func (c *Connection) Diagnose() (report *DiagnoseReport) {
+	report = &DiagnoseReport{
+		Id:        c.Id,
+		Mode:      c.Profile.Mode,
+		Status:    DiagnosePass,
+		Iface:     c.diagnoseIface(),
+		Timestamp: time.Now().Unix(),
+		Checks:    []*DiagnoseCheck{},
+	}
+
+	if c.Data.Status != Connected {
+		report.add("connection", DiagnoseFail,
+			"Profile status is %s", c.Data.Status)
+	} else {
+		report.add("connection", DiagnosePass, "Profile connected")
+	}
+
+	c.diagnoseInterface(report)
+	c.diagnoseRoutes(report)
+	c.diagnoseDns(report)
+	c.diagnoseMtu(report)
+	c.diagnoseGateway(report)
+	c.diagnoseHandshake(report)
+	c.diagnoseKeepalive(report)
+
+	return
+}
+
+func (c *Connection) diagnoseInterface(report *DiagnoseReport) {
+	if c.isNetstack() {
+		report.add("interface", DiagnoseSkip, "Userspace netstack in use")
+		return
+	}
+
+	iface := c.diagnoseIface()
+	if iface == "" {
+		report.add("interface", DiagnoseFail, "Tunnel interface unknown")
+		return
+	}
+
+	ifc, err := net.InterfaceByName(iface)
+	if err != nil {
+		report.add("interface", DiagnoseFail,
+			"Tunnel interface %s not found", iface)
+		return
+	}
+
+	if ifc.Flags&net.FlagUp == 0 {
+		report.add("interface", DiagnoseFail,
+			"Tunnel interface %s is down", iface)
+		return
+	}
+
+	report.add("interface", DiagnosePass,
+		"Tunnel interface %s is up, mtu %d", iface, ifc.MTU)
+}
+
+func probeAddr(prefix netip.Prefix) netip.Addr {
+	prefix = prefix.Masked()
+
+	public := netip.MustParseAddr("8.8.8.8")
+	if prefix.Addr().Is6() {
+		public = netip.MustParseAddr("2001:4860:4860::8888")
+	}
+	if prefix.Bits() <= 8 && prefix.Contains(public) {
+		return public
+	}
+
+	if prefix.Bits() >= prefix.Addr().BitLen() {
+		return prefix.Addr()
+	}
+
+	return prefix.Addr().Next()
+}
+
+func routeInterface(addr netip.Addr) (iface string, err error) {
+	output := ""
+
+	switch runtime.GOOS {
+	case "linux":
+		output, err = utils.ExecCombinedOutput(
+			"ip", "route", "get", addr.String())
+		if err != nil {
+			return
+		}
+
+		fields := strings.Fields(output)
+		for i, field := range fields {
+			if field == "dev" && i+1 < len(fields) {
+				iface = fields[i+1]
+				break
+			}
+		}
+		break
+	case "darwin":
+		output, err = utils.ExecCombinedOutput(
+			"/sbin/route", "-n", "get", addr.String())
+		if err != nil {
+			return
+		}
+
+		for _, line := range strings.Split(output, "\n") {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "interface:") {
+				iface = strings.TrimSpace(line[10:])
+				break
+			}
+		}
+		break
+	case "windows":
+		output, err = utils.ExecCombinedOutput(
+			"powershell.exe", "-NoProfile", "-Command",
+			fmt.Sprintf("(Find-NetRoute -RemoteIPAddress '%s' | "+
+				"Select-Object -First 1).InterfaceAlias", addr),
+		)
+		if err != nil {
+			return
+		}
+
+		iface = strings.TrimSpace(output)
+		break
+	}
+
+	if iface == "" {
+		err = &errortypes.ParseError{
+			errors.Newf("connection: Failed to find route for %s", addr),
+		}
+		return
+	}
+
+	return
+}
+
+func (c *Connection) checkRoute(addr netip.Addr) (ok bool, msg string) {
+	iface := c.diagnoseIface()
+
+	routeIface, err := routeInterface(addr)
+	if err != nil {
+		msg = fmt.Sprintf("%s lookup failed", addr)
+		return
+	}
+
+	if routeIface != iface {
+		msg = fmt.Sprintf("%s routed through %s", addr, routeIface)
+		return
+	}
+
+	ok = true
+	return
+}
+
+func (c *Connection) diagnoseRoutes(report *DiagnoseReport) {
+	if c.isNetstack() || c.Profile.ProxyMode {
+		report.add("routes", DiagnoseSkip,
+			"Routes not installed in proxy mode")
+		return
+	}
+
+	entries := c.RouteEntries()
+	if len(entries) == 0 {
+		report.add("routes", DiagnoseSkip, "No tunnel routes")
+		return
+	}
+
+	checked := 0
+	failed := []string{}
+	for _, entry := range entries {
+		if entry.NetGateway {
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(entry.Network)
+		if err != nil {
+			continue
+		}
+
+		if checked >= diagnoseMaxRoutes {
+			break
+		}
+		checked += 1
+
+		ok, msg := c.checkRoute(probeAddr(prefix))
+		if !ok {
+			failed = append(failed, fmt.Sprintf("%s (%s)", prefix, msg))
+		}
+	}
+
+	if checked == 0 {
+		report.add("routes", DiagnoseSkip, "No tunnel routes")
+		return
+	}
+
+	if len(failed) > 0 {
+		report.add("routes", DiagnoseFail, "%d of %d routes missing: %s",
+			len(failed), checked, strings.Join(failed, ", "))
+		return
+	}
+
+	report.add("routes", DiagnosePass, "%d routes installed", checked)
+}
+
+func dnsProbe(server string) (err error) {
+	builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{
+		ID:               uint16(time.Now().UnixNano()),
+		RecursionDesired: true,
+	})
+
+	err = builder.StartQuestions()
+	if err != nil {
+		return
+	}
+
+	err = builder.Question(dnsmessage.Question{
+		Name:  dnsmessage.MustNewName("."),
+		Type:  dnsmessage.TypeNS,
+		Class: dnsmessage.ClassINET,
+	})
+	if err != nil {
+		return
+	}
+
+	query, err := builder.Finish()
+	if err != nil {
+		return
+	}
+
+	conn, err := net.DialTimeout("udp",
+		net.JoinHostPort(server, "53"), diagnoseDnsTimeout)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(diagnoseDnsTimeout))
+
+	_, err = conn.Write(query)
+	if err != nil {
+		return
+	}
+
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return
+	}
+
+	parser := dnsmessage.Parser{}
+	header, err := parser.Start(buf[:n])
+	if err != nil {
+		return
+	}
+
+	if header.RCode != dnsmessage.RCodeSuccess {
+		err = &errortypes.RequestError{
+			errors.Newf("connection: DNS response %s", header.RCode),
+		}
+		return
+	}
+
+	return
+}
+
+func (c *Connection) diagnoseDns(report *DiagnoseReport) {
+	if c.Profile.IsDisableDns() {
+		report.add("dns", DiagnoseSkip, "Tunnel DNS disabled")
+		return
+	}
+
+	servers := c.Data.DnsServers
+	if len(servers) == 0 {
+		report.add("dns", DiagnoseSkip, "No tunnel DNS servers")
+		return
+	}
+
+	failed := []string{}
+	for _, server := range servers {
+		if !c.isNetstack() {
+			addr, err := netip.ParseAddr(server)
+			if err == nil {
+				ok, msg := c.checkRoute(addr)
+				if !ok {
+					failed = append(failed, fmt.Sprintf(
+						"%s not routed through tunnel (%s)", server, msg))
+					continue
+				}
+			}
+		}
+
+		err := dnsProbe(server)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", server, err))
+		}
+	}
+
+	if len(failed) > 0 && !c.isNetstack() {
+		report.add("dns", DiagnoseFail, "Tunnel DNS failed: %s",
+			strings.Join(failed, ", "))
+	} else if len(failed) > 0 {
+		report.add("dns", DiagnoseSkip,
+			"Tunnel DNS only reachable through netstack")
+	} else {
+		report.add("dns", DiagnosePass, "Tunnel DNS servers responding: %s",
+			strings.Join(servers, ", "))
+	}
+
+	if c.Profile.ProxyMode || c.isNetstack() {
+		report.add("dns_leak", DiagnoseSkip,
+			"System resolver not used in proxy mode")
+		return
+	}
+
+	system := dnsfwd.SystemServers()
+	if dnsfwd.Active() {
+		report.add("dns_leak", DiagnosePass,
+			"System resolver using local dns forwarder")
+		return
+	}
+
+	for _, sysServer := range system {
+		for _, server := range servers {
+			if sysServer == server {
+				report.add("dns_leak", DiagnosePass,
+					"System resolver using tunnel DNS server %s", server)
+				return
+			}
+		}
+
+		addr, err := netip.ParseAddr(sysServer)
+		if err == nil && addr.IsLoopback() {
+			c.diagnoseDnsStub(report, sysServer, servers)
+			return
+		}
+	}
+
+	report.add("dns_leak", DiagnoseFail,
+		"System resolver using %s instead of tunnel DNS servers",
+		strings.Join(system, ", "))
+}
+
+func (c *Connection) diagnoseDnsStub(report *DiagnoseReport,
+	stub string, servers []string) {
+
+	linkServers, err := dns.LinkServers(c.Data.Iface)
+	if err != nil {
+		report.add("dns_leak", DiagnoseFail,
+			"Failed to read local stub %s link servers: %s", stub, err)
+		return
+	}
+
+	if linkServers == nil {
+		report.add("dns_leak", DiagnoseSkip,
+			"System resolver using local stub %s", stub)
+		return
+	}
+
+	for _, linkServer := range linkServers {
+		for _, server := range servers {
+			if linkServer == server {
+				report.add("dns_leak", DiagnosePass,
+					"Local stub %s using tunnel DNS server %s",
+					stub, server)
+				return
+			}
+		}
+	}
+
+	report.add("dns_leak", DiagnoseFail,
+		"Local stub %s not using tunnel DNS servers on %s",
+		stub, c.Data.Iface)
+}
+
+func (c *Connection) diagnoseTarget() string {
+	if c.Data.GatewayAddr != "" {
+		return c.Data.GatewayAddr
+	}
+
+	if len(c.Data.DnsServers) > 0 && !c.Profile.IsDisableDns() {
+		return c.Data.DnsServers[0]
+	}
+
+	return ""
+}
+
+func (c *Connection) diagnoseMtu(report *DiagnoseReport) {
+	if c.isNetstack() {
+		report.add("mtu", DiagnoseSkip, "Userspace netstack in use")
+		return
+	}
+
+	target := c.diagnoseTarget()
+	if target == "" {
+		report.add("mtu", DiagnoseSkip, "No tunnel address to probe")
+		return
+	}
+
+	sizes := diagnoseMtuSizes
+	ifc, err := net.InterfaceByName(c.diagnoseIface())
+	if err == nil && ifc.MTU > 0 {
+		overhead := 28
+		ip := net.ParseIP(target)
+		if ip != nil && ip.To4() == nil {
+			overhead = 48
+		}
+
+		sizes = []int{ifc.MTU - overhead}
+		for _, size := range diagnoseMtuSizes {
+			if size < sizes[0] {
+				sizes = append(sizes, size)
+			}
+		}
+	}
+
+	for i, size := range sizes {
+		if !pingDf(target, size) {
+			continue
+		}
+
+		if i == 0 {
+			report.add("mtu", DiagnosePass,
+				"Unfragmented %d byte payload to %s", size, target)
+		} else {
+			report.add("mtu", DiagnoseFail,
+				"Largest unfragmented payload to %s is %d bytes",
+				target, size)
+		}
+		return
+	}
+
+	report.add("mtu", DiagnoseFail,
+		"No unfragmented payload reached %s", target)
+}
+
+func (c *Connection) diagnoseGateway(report *DiagnoseReport) {
+	if c.isNetstack() {
+		report.add("gateway", DiagnoseSkip, "Userspace netstack in use")
+		return
+	}
+
+	if c.Data.GatewayAddr == "" {
+		report.add("gateway", DiagnoseSkip, "Gateway address unknown")
+		return
+	}
+
+	if !pingDf(c.Data.GatewayAddr, 56) {
+		report.add("gateway", DiagnoseFail,
+			"Gateway %s unreachable", c.Data.GatewayAddr)
+		return
+	}
+
+	report.add("gateway", DiagnosePass,
+		"Gateway %s reachable", c.Data.GatewayAddr)
+}
+
+func (c *Connection) diagnoseHandshake(report *DiagnoseReport) {
+	if !c.isWgActive() {
+		report.add("handshake", DiagnoseSkip, "Not a WireGuard connection")
+		return
+	}
+
+	err := c.Wg.updateHandshake()
+	if err != nil {
+		report.add("handshake", DiagnoseFail,
+			"Failed to read handshake: %s", err)
+		return
+	}
+
+	handshake := c.Wg.getHandshake()
+	if handshake == 0 {
+		report.add("handshake", DiagnoseFail, "No handshake completed")
+		return
+	}
+
+	age := time.Now().Unix() - int64(handshake)
+	if age > diagnoseHandshakeAge {
+		report.add("handshake", DiagnoseFail,
+			"Last handshake %d seconds ago", age)
+		return
+	}
+
+	report.add("handshake", DiagnosePass,
+		"Last handshake %d seconds ago", age)
+}
+
+func (c *Connection) diagnoseKeepalive(report *DiagnoseReport) {
+	if !c.isWgActive() {
+		report.add("keepalive", DiagnoseSkip, "Not a WireGuard connection")
+		return
+	}
+
+	if c.Data.GatewayAddr == "" || c.Data.WebPort == 0 {
+		report.add("keepalive", DiagnoseSkip, "Keepalive endpoint unknown")
+		return
+	}
+
+	start := time.Now()
+	data, _, err := c.Wg.ping()
+	if err != nil {
+		report.add("keepalive", DiagnoseFail,
+			"Keepalive request failed: %s", err)
+		return
+	}
+
+	if data == nil || !data.Status {
+		report.add("keepalive", DiagnoseFail,
+			"Keepalive endpoint rejected connection")
+		return
+	}
+
+	report.add("keepalive", DiagnosePass, "Keepalive response in %s",
+		time.Since(start).Round(time.Millisecond))
+}
@@ -0,0 +1,34 @@
+package connection
+
+import (
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/dns"
+	"github.com/pritunl/pritunl-client-electron/service/dnsfwd"
+	"github.com/sirupsen/logrus"
+)
+
+// DnsForward registers the tunnel resolvers with the local forwarder and
+// returns the servers the system resolver should be configured with
+func (c *Connection) DnsForward(servers, domains []string,
+	defaultRoute bool) []string {
+
+	if !dnsfwd.Active() || dns.SplitSupported() {
+		return servers
+	}
+
+	fwdDomains := []string{}
+	fwdDomains = append(fwdDomains, domains...)
+	fwdDomains = append(fwdDomains, config.Config.DnsForwarderDomains...)
+
+	fwdServers := dnsfwd.Register(c.Id, fwdDomains, servers, defaultRoute)
+	if fwdServers == nil {
+		return servers
+	}
+
+	logrus.WithFields(c.Fields(logrus.Fields{
+		"dns_servers":    servers,
+		"search_domains": fwdDomains,
+	})).Info("connection: Forwarding tunnel DNS")
+
+	return fwdServers
+}
@@ -0,0 +1,22 @@
+package connection
+
+import (
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/log"
+	"github.com/sirupsen/logrus"
+)
+
+func (c *Connection) PushHistory(entry *log.HistoryEntry) {
+	entry.Timestamp = time.Now().Unix()
+	entry.StateId = c.State.id
+	entry.Mode = c.Profile.Mode
+
+	err := log.ProfilePushHistory(c.Id, entry)
+	if err != nil {
+		logrus.WithFields(c.Fields(logrus.Fields{
+			"event": entry.Event,
+			"error": err,
+		})).Error("connection: Failed to write profile history")
+	}
+}
@@ -0,0 +1,124 @@
+package connection
+
+import (
+	"net"
+	"net/url"
+	"sync"
+
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/killswitch"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	resolvedHosts     = map[string][]string{}
+	resolvedHostsLock = sync.Mutex{}
+)
+
+func (c *Connection) KillSwitchEnabled() bool {
+	return killswitch.Supported() && !c.Profile.ProxyMode &&
+		(c.Profile.KillSwitch || config.Config.KillSwitch)
+}
+
+// resolveHost resolves the host for the kill switch rules, when cached is
+// set the previous result is used since DNS is blocked by the kill switch
+func resolveHost(host string, cached bool) (addrs []string) {
+	addrs = []string{}
+
+	if host == "" {
+		return
+	}
+
+	ip := net.ParseIP(host)
+	if ip != nil {
+		addrs = append(addrs, ip.String())
+		return
+	}
+
+	if cached {
+		resolvedHostsLock.Lock()
+		addrs = append(addrs, resolvedHosts[host]...)
+		resolvedHostsLock.Unlock()
+
+		if len(addrs) > 0 {
+			return
+		}
+	}
+
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"host":  host,
+			"error": err,
+		}).Warn("connection: Failed to resolve kill switch host")
+		return
+	}
+
+	for _, ip := range ips {
+		addrs = append(addrs, ip.String())
+	}
+
+	resolvedHostsLock.Lock()
+	resolvedHosts[host] = addrs
+	resolvedHostsLock.Unlock()
+
+	return
+}
+
+func (c *Connection) killSwitchAddrs() (addrs []string) {
+	addrs = []string{}
+
+	for _, remote := range c.Data.Remotes {
+		if remote.Addr4 != "" || remote.Addr6 != "" {
+			addrs = append(addrs, remote.Addr4, remote.Addr6)
+		} else {
+			addrs = append(addrs, resolveHost(remote.Host, false)...)
+		}
+	}
+
+	for _, syncHost := range c.Profile.SyncHosts {
+		syncUrl, err := url.Parse(syncHost)
+		if err != nil {
+			continue
+		}
+		addrs = append(addrs, resolveHost(syncUrl.Hostname(), false)...)
+	}
+
+	return
+}
+
+func (c *Connection) KillSwitchAllow(iface string, hosts ...string) {
+	if !c.KillSwitchEnabled() {
+		return
+	}
+
+	active := killswitch.IsActive(c.Id)
+
+	addrs := []string{}
+	if !active {
+		addrs = c.killSwitchAddrs()
+	}
+	for _, host := range hosts {
+		addrs = append(addrs, resolveHost(host, active)...)
+	}
+
+	err := killswitch.Allow(c.Id, iface, addrs)
+	if err != nil {
+		logrus.WithFields(c.Fields(logrus.Fields{
+			"error": err,
+		})).Error("connection: Failed to apply kill switch")
+	}
+}
+
+func (c *Connection) KillSwitchRemove() {
+	if !killswitch.IsActive(c.Id) {
+		return
+	}
+
+	err := killswitch.Remove(c.Id)
+	if err != nil {
+		logrus.WithFields(c.Fields(logrus.Fields{
+			"error": err,
+		})).Error("connection: Failed to remove kill switch")
+	}
+}
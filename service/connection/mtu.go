@@ -0,0 +1,167 @@
+package connection
+
+import (
+	"net"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	mtuMax        = 1500
+	mtuMin        = 576
+	mtuIcmpHeader = 28
+	mtuOvpnUdp    = 28
+	mtuOvpnHeader = 72
+	mtuWgHeader   = 80
+	mtuWgDefault  = 1420
+
+	mtuPingAttempts = 3
+)
+
+// pingDf sends a single ping with the do not fragment bit set
+func pingDf(addr string, size int) bool {
+	sizeStr := strconv.Itoa(size)
+	var err error
+
+	switch runtime.GOOS {
+	case "linux":
+		_, err = utils.ExecCombinedOutput("ping", "-c", "1", "-W", "1",
+			"-M", "do", "-s", sizeStr, addr)
+		break
+	case "darwin":
+		_, err = utils.ExecCombinedOutput("/sbin/ping", "-c", "1",
+			"-t", "1", "-D", "-s", sizeStr, addr)
+		break
+	case "windows":
+		output := ""
+		output, err = utils.ExecCombinedOutput("ping.exe", "-n", "1",
+			"-w", "1000", "-f", "-l", sizeStr, addr)
+		if err == nil && !strings.Contains(output, "TTL=") {
+			return false
+		}
+		break
+	default:
+		return false
+	}
+
+	return err == nil
+}
+
+func pingDfRetry(addr string, size int) bool {
+	for i := 0; i < mtuPingAttempts; i++ {
+		if pingDf(addr, size) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func mtuResolve(host string) (addr net.IP) {
+	addr = net.ParseIP(host)
+	if addr != nil {
+		return
+	}
+
+	addrs, err := net.LookupIP(host)
+	if err != nil || len(addrs) == 0 {
+		return
+	}
+
+	addr = addrs[0]
+	return
+}
+
+// DiscoverPathMtu probes the path to the remote with unfragmented pings,
+// zero is returned when the remote does not respond to ping
+func (c *Connection) DiscoverPathMtu(host string) (pathMtu int) {
+	if !config.Config.MtuDiscovery {
+		return
+	}
+
+	ip := mtuResolve(host)
+	if ip == nil {
+		return
+	}
+
+	if ip.To4() == nil {
+		logrus.WithFields(c.Fields(logrus.Fields{
+			"remote": ip.String(),
+		})).Info("connection: Skipping mtu discovery for ipv6 remote")
+		return
+	}
+	addr := ip.String()
+
+	if pingDfRetry(addr, mtuMax-mtuIcmpHeader) {
+		pathMtu = mtuMax
+	} else if pingDfRetry(addr, mtuMin-mtuIcmpHeader) {
+		low := mtuMin
+		high := mtuMax - 1
+
+		for low < high {
+			mid := (low + high + 1) / 2
+
+			if c.State.IsStop() {
+				return
+			}
+
+			if pingDfRetry(addr, mid-mtuIcmpHeader) {
+				low = mid
+			} else {
+				high = mid - 1
+			}
+		}
+
+		pathMtu = low
+	} else {
+		logrus.WithFields(c.Fields(logrus.Fields{
+			"remote": addr,
+		})).Info("connection: Remote does not respond to ping, " +
+			"skipping mtu discovery")
+		return
+	}
+
+	c.Data.PathMtu = pathMtu
+
+	logrus.WithFields(c.Fields(logrus.Fields{
+		"remote":   addr,
+		"path_mtu": pathMtu,
+	})).Info("connection: Discovered path mtu")
+
+	return
+}
+
+func wgMtu(pathMtu, mtu int) int {
+	if mtu == 0 {
+		mtu = mtuWgDefault
+	}
+
+	if pathMtu != 0 && pathMtu-mtuWgHeader < mtu {
+		mtu = pathMtu - mtuWgHeader
+	}
+
+	return mtu
+}
+
+func ovpnMtu(pathMtu, tunMtu, mssFix int) (int, int) {
+	if pathMtu == 0 || pathMtu >= mtuMax {
+		return tunMtu, mssFix
+	}
+
+	safeTunMtu := pathMtu - mtuOvpnUdp - mtuOvpnHeader
+	if tunMtu == 0 || safeTunMtu < tunMtu {
+		tunMtu = safeTunMtu
+	}
+
+	safeMssFix := pathMtu - mtuOvpnUdp
+	if mssFix == 0 || safeMssFix < mssFix {
+		mssFix = safeMssFix
+	}
+
+	return tunMtu, mssFix
+}
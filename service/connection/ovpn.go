@@ -14,20 +14,25 @@ import (
 	"io"
 	"io/ioutil"
 	"net"
+	"net/netip"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"runtime/debug"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/pritunl-client-electron/service/command"
+	"github.com/pritunl/pritunl-client-electron/service/dns"
 	"github.com/pritunl/pritunl-client-electron/service/errortypes"
 	"github.com/pritunl/pritunl-client-electron/service/log"
+	"github.com/pritunl/pritunl-client-electron/service/metrics"
 	"github.com/pritunl/pritunl-client-electron/service/parser"
+	"github.com/pritunl/pritunl-client-electron/service/proxy"
 	"github.com/pritunl/pritunl-client-electron/service/sprofile"
 	"github.com/pritunl/pritunl-client-electron/service/tuntap"
 	"github.com/pritunl/pritunl-client-electron/service/utils"
@@ -54,6 +59,10 @@ type Ovpn struct {
 	stderr         io.ReadCloser
 	outputBuffer   chan string
 	outputWait     sync.WaitGroup
+	proxy          *proxy.Proxy
+	proxyLock      sync.Mutex
+	dnsPath        string
+	statusPath     string
 }
 
 type AuthData struct {
@@ -81,6 +90,7 @@ func (o *Ovpn) Fields() logrus.Fields {
 		"ovpn_last_auth_failed": utils.SinceFormatted(o.lastAuthFailed),
 		"ovpn_cmd":              o.cmd != nil,
 		"ovpn_remotes":          remotes,
+		"ovpn_proxy":            o.proxy != nil,
 	}
 }
 
@@ -194,9 +204,22 @@ func (o *Ovpn) Connect(data *ConnData) (err error) {
 	o.parsedPrfl = parser.Import(
 		o.conn.Profile.Data,
 		o.remotes,
-		o.conn.Profile.DisableGateway,
-		o.conn.Profile.DisableDns,
+		o.conn.Profile.IsDisableGateway(),
+		o.conn.Profile.IsDisableDns(),
 	)
+	o.parsedPrfl.RouteIncludes = o.conn.Profile.RouteIncludes
+	o.parsedPrfl.RouteExcludes = o.conn.Profile.RouteExcludes
+	o.parsedPrfl.RouteNoPull = o.conn.Profile.ProxyMode
+
+	if len(o.remotes) > 0 && strings.HasPrefix(o.remotes[0].Proto, "udp") {
+		pathMtu := o.conn.DiscoverPathMtu(o.remotes[0].Host)
+		o.parsedPrfl.TunMtu, o.parsedPrfl.MssFix = ovpnMtu(
+			pathMtu, o.parsedPrfl.TunMtu, o.parsedPrfl.MssFix)
+	}
+	o.conn.Data.Mtu = o.parsedPrfl.TunMtu
+	if o.conn.Data.Mtu == 0 {
+		o.conn.Data.Mtu = mtuMax
+	}
 
 	if runtime.GOOS == "windows" {
 		n := GlobalStore.Len()
@@ -417,6 +440,14 @@ func (o *Ovpn) WatchConnection() (err error) {
 func (o *Ovpn) Disconnect() {
 	o.Close()
 
+	o.stopProxy()
+
+	if o.dnsPath != "" {
+		dns.Clear(o.conn.Data.Iface)
+	}
+
+	o.conn.SplitTunnelStop()
+
 	if o.tapIface != "" {
 		tuntap.Release(o.tapIface)
 	}
@@ -438,9 +469,18 @@ func (o *Ovpn) write(data *ConnData) (
 	pth = filepath.Join(rootDir, o.conn.Id)
 	prflData := o.parsedPrfl.Export()
 
+	// The management interface is only used on Windows, other platforms
+	// read transfer stats from the status file
 	if runtime.GOOS == "windows" {
 		o.managementPort = ManagementPortAcquire()
+	} else {
+		o.statusPath = filepath.Join(rootDir, o.conn.Id+"-status")
+		o.conn.State.AddPath(o.statusPath)
 
+		prflData += fmt.Sprintf("status %s 1\n", o.statusPath)
+	}
+
+	if o.managementPort != 0 {
 		managementPassPath, e := o.writeManagementPass()
 		if e != nil {
 			err = e
@@ -667,7 +707,7 @@ func (o *Ovpn) writeUp() (pth string, err error) {
 	script := ""
 	switch runtime.GOOS {
 	case "darwin":
-		if o.conn.Profile.DisableDns {
+		if o.conn.Profile.IsDisableDns() {
 			script = blockScript
 		} else if o.conn.Profile.ForceDns {
 			DnsForced = true
@@ -677,24 +717,12 @@ func (o *Ovpn) writeUp() (pth string, err error) {
 		}
 		break
 	case "linux":
-		resolved := true
-
-		resolvData, _ := ioutil.ReadFile("/etc/resolv.conf")
-		if resolvData != nil {
-			resolvDataStr := string(resolvData)
-			if !strings.Contains(resolvDataStr, "systemd-resolved") &&
-				!strings.Contains(resolvDataStr, "127.0.0.53") {
-
-				resolved = false
-			}
-		}
-
-		if o.conn.Profile.DisableDns {
+		if o.conn.Profile.IsDisableDns() {
 			script = blockScript
-		} else if resolved {
-			script = resolvedScript
 		} else {
-			script = resolvScript
+			o.dnsPath = filepath.Join(rootDir, o.conn.Id+"-dns.conf")
+			o.conn.State.AddPath(o.dnsPath)
+			script = fmt.Sprintf(dnsScriptLinux, o.dnsPath)
 		}
 		break
 	default:
@@ -728,32 +756,14 @@ func (o *Ovpn) writeDown() (pth string, err error) {
 	script := ""
 	switch runtime.GOOS {
 	case "darwin":
-		if o.conn.Profile.DisableDns {
+		if o.conn.Profile.IsDisableDns() {
 			script = blockScript
 		} else {
 			script = downScriptDarwin
 		}
 		break
 	case "linux":
-		resolved := true
-
-		resolvData, _ := ioutil.ReadFile("/etc/resolv.conf")
-		if resolvData != nil {
-			resolvDataStr := string(resolvData)
-			if !strings.Contains(resolvDataStr, "systemd-resolved") &&
-				!strings.Contains(resolvDataStr, "127.0.0.53") {
-
-				resolved = false
-			}
-		}
-
-		if o.conn.Profile.DisableDns {
-			script = blockScript
-		} else if resolved {
-			script = resolvedScript
-		} else {
-			script = resolvScript
-		}
+		script = blockScript
 		break
 	default:
 		panic("profile: Not implemented")
@@ -1003,10 +1013,19 @@ func (o *Ovpn) parseLine(line string) {
 		o.connected = true
 		o.conn.Data.Status = Connected
 		o.conn.Data.Timestamp = time.Now().Unix() - 3
+		if o.dnsPath != "" {
+			o.setDns()
+		}
+		o.conn.SplitTunnelStart()
 		o.conn.Data.UpdateEvent()
+		o.conn.Client.Connected()
 
 		o.conn.Data.ValidateAuthToken()
 
+		if o.conn.Profile.ProxyMode {
+			o.startProxy()
+		}
+
 		go func() {
 			defer func() {
 				panc := recover()
@@ -1055,6 +1074,12 @@ func (o *Ovpn) parseLine(line string) {
 		line, "auth-failure") && !o.authFailed {
 
 		o.authFailed = true
+		metrics.ProfileAuthFailure(o.conn.Id)
+		o.conn.PushHistory(&log.HistoryEntry{
+			Event:  log.HistoryAuthError,
+			Remote: o.conn.Data.ServerAddr,
+			Reason: "ovpn_auth_error",
+		})
 		o.conn.Data.ResetAuthToken()
 		o.conn.State.NoReconnect("ovpn_auth_error")
 		o.conn.State.SetStop()
@@ -1074,12 +1099,26 @@ func (o *Ovpn) parseLine(line string) {
 			o.lastAuthFailed = time.Now()
 			o.conn.Data.SendProfileEvent("auth_error")
 		}
+	} else if strings.Contains(line, "TUN/TAP device ") &&
+		strings.HasSuffix(line, " opened") {
+
+		sIndex := strings.Index(line, "TUN/TAP device ") + 15
+		eIndex := strings.LastIndex(line, " opened")
+
+		if sIndex < eIndex {
+			o.conn.Data.Iface = line[sIndex:eIndex]
+			o.conn.KillSwitchAllow(o.conn.Data.Iface)
+		}
 	} else if strings.Contains(line, "link remote:") {
 		sIndex := strings.LastIndex(line, "]") + 1
 		eIndex := strings.LastIndex(line, ":")
 
 		o.conn.Data.ServerAddr = line[sIndex:eIndex]
 		o.conn.Data.UpdateEvent()
+		o.conn.PushHistory(&log.HistoryEntry{
+			Event:  log.HistoryRemote,
+			Remote: o.conn.Data.ServerAddr,
+		})
 	} else if strings.Contains(line, "network/local/netmask") {
 		eIndex := strings.LastIndex(line, "/")
 		line = line[:eIndex]
@@ -1139,7 +1178,210 @@ func (o *Ovpn) parseLine(line string) {
 			o.conn.Data.ClientAddr = clientAddr
 			o.conn.Data.UpdateEvent()
 		}
+	} else if network := parseOvpnRoute(line); network != "" {
+		o.addRoute(network)
+	}
+}
+
+func parseOvpnRoute(line string) (network string) {
+	fields := strings.Fields(line)
+
+	for i, field := range fields {
+		switch strings.ToLower(field) {
+		case "net_route_v4_add:", "net_route_v6_add:":
+			if i+1 < len(fields) {
+				network = fields[i+1]
+			}
+		case "add":
+			if i == 0 || i+1 >= len(fields) {
+				continue
+			}
+
+			cmd := strings.ToLower(fields[i-1])
+			if cmd != "route" && !strings.HasSuffix(cmd, "route.exe") &&
+				!strings.HasSuffix(cmd, "/route") {
+
+				continue
+			}
+
+			args := fields[i+1:]
+			if len(args) >= 4 && args[0] == "-net" {
+				network = args[1] + "/" + args[3]
+			} else if len(args) >= 2 &&
+				(args[0] == "-net" || args[0] == "-inet6") {
+
+				network = args[1]
+			} else if len(args) >= 3 && strings.EqualFold(args[1], "mask") {
+				network = args[0] + "/" + args[2]
+			} else {
+				network = args[0]
+			}
+		default:
+			continue
+		}
+		break
+	}
+
+	if network == "" {
+		return
+	}
+
+	addr, mask, hasMask := strings.Cut(network, "/")
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		network = ""
+		return
+	}
+	ip = ip.Unmap()
+
+	bits := ip.BitLen()
+	if hasMask {
+		maskIp := net.ParseIP(mask)
+		if maskIp != nil && maskIp.To4() != nil {
+			bits, _ = net.IPMask(maskIp.To4()).Size()
+		} else {
+			bits, err = strconv.Atoi(mask)
+			if err != nil {
+				network = ""
+				return
+			}
+		}
+	}
+
+	prefix, err := ip.Prefix(bits)
+	if err != nil {
+		network = ""
+		return
+	}
+	network = prefix.String()
+
+	return
+}
+
+func (o *Ovpn) addRoute(network string) {
+	netGateway := false
+	for _, exclude := range o.conn.Profile.RouteExcludes {
+		if exclude == network {
+			netGateway = true
+			break
+		}
+	}
+
+	route := &Route{
+		Network:    network,
+		NetGateway: netGateway,
+	}
+
+	if strings.Contains(network, ":") {
+		for _, rte := range o.conn.Data.Routes6 {
+			if rte.Network == network {
+				return
+			}
+		}
+		o.conn.Data.Routes6 = append(o.conn.Data.Routes6, route)
+	} else {
+		for _, rte := range o.conn.Data.Routes {
+			if rte.Network == network {
+				return
+			}
+		}
+		o.conn.Data.Routes = append(o.conn.Data.Routes, route)
+	}
+}
+
+func (o *Ovpn) setDns() {
+	data, err := ioutil.ReadFile(o.dnsPath)
+	if err != nil {
+		logrus.WithFields(o.conn.Fields(logrus.Fields{
+			"error": err,
+		})).Error("profile: Failed to read dns options")
+		return
+	}
+
+	servers := []string{}
+	domains := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[0] != "dhcp-option" {
+			continue
+		}
+
+		switch strings.ToUpper(fields[1]) {
+		case "DNS", "DNS6":
+			servers = append(servers, fields[2])
+		case "DOMAIN", "DOMAIN-SEARCH", "ADAPTER_DOMAIN_SUFFIX":
+			domains = append(domains, fields[2:]...)
+		}
+	}
+
+	if len(servers) == 0 {
+		return
+	}
+
+	o.conn.Data.DnsServers = servers
+	o.conn.Data.SearchDomains = domains
+
+	defaultRoute := o.conn.routeInfo().defaultGateway || len(domains) == 0
+
+	err = dns.Set(&dns.Config{
+		Iface:        o.conn.Data.Iface,
+		Servers:      o.conn.DnsForward(servers, domains, defaultRoute),
+		Domains:      domains,
+		DefaultRoute: defaultRoute,
+	})
+	if err != nil {
+		logrus.WithFields(o.conn.Fields(logrus.Fields{
+			"error": err,
+		})).Error("profile: Failed to configure dns")
+		return
+	}
+}
+
+func (o *Ovpn) startProxy() {
+	o.proxyLock.Lock()
+	defer o.proxyLock.Unlock()
+
+	if o.proxy != nil {
+		return
+	}
+
+	if o.conn.Data.ClientAddr == "" {
+		logrus.WithFields(o.conn.Fields(nil)).Error(
+			"profile: Missing tunnel address for proxy")
+		return
+	}
+
+	prxy := &proxy.Proxy{
+		Address: o.conn.Profile.ProxyAddress,
+		Dial: proxy.BoundDial(o.conn.Data.ClientAddr,
+			o.conn.Data.DnsServers),
+	}
+
+	err := prxy.Start()
+	if err != nil {
+		logrus.WithFields(o.conn.Fields(logrus.Fields{
+			"error": err,
+		})).Error("profile: Failed to start proxy")
+		o.conn.Data.SendProfileEvent("proxy_error")
+		return
+	}
+
+	o.proxy = prxy
+	o.conn.Data.ProxyAddr = prxy.Address
+	o.conn.Data.UpdateEvent()
+}
+
+func (o *Ovpn) stopProxy() {
+	o.proxyLock.Lock()
+	defer o.proxyLock.Unlock()
+
+	if o.proxy == nil {
+		return
 	}
+
+	o.proxy.Close()
+	o.proxy = nil
+	o.conn.Data.ProxyAddr = ""
 }
 
 func (o *Ovpn) pushOutput(output string) {
@@ -1212,6 +1454,133 @@ func (o *Ovpn) sendManagementCommand(cmd string) (err error) {
 	return
 }
 
+func (o *Ovpn) getStatusTransfer() (rx, tx uint64, err error) {
+	data, err := ioutil.ReadFile(o.statusPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "profile: Failed to read status file"),
+		}
+		return
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		key, val, ok := strings.Cut(strings.TrimSpace(line), ",")
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "TCP/UDP read bytes":
+			rx, err = strconv.ParseUint(val, 10, 64)
+		case "TCP/UDP write bytes":
+			tx, err = strconv.ParseUint(val, 10, 64)
+		}
+		if err != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(err, "profile: Failed to parse status file"),
+			}
+			return
+		}
+	}
+
+	return
+}
+
+func (o *Ovpn) GetTransfer() (rx, tx uint64, err error) {
+	if o.statusPath != "" {
+		rx, tx, err = o.getStatusTransfer()
+		return
+	}
+
+	if o.managementPort == 0 {
+		return
+	}
+
+	o.managementLock.Lock()
+	defer o.managementLock.Unlock()
+
+	conn, err := net.DialTimeout(
+		"tcp",
+		fmt.Sprintf("127.0.0.1:%d", o.managementPort),
+		3*time.Second,
+	)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "profile: Failed to open socket"),
+		}
+		return
+	}
+	defer conn.Close()
+
+	err = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "profile: Failed set deadline"),
+		}
+		return
+	}
+
+	_, err = conn.Write([]byte(fmt.Sprintf(
+		"%s\nbytecount 1\n", o.managementPass)))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "profile: Failed to write socket command"),
+		}
+		return
+	}
+
+	reader := bufio.NewReader(conn)
+	for {
+		line, e := reader.ReadString('\n')
+		if e != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "profile: Failed to read bytecount"),
+			}
+			return
+		}
+
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, ">BYTECOUNT:") {
+			continue
+		}
+
+		counts := strings.Split(line[11:], ",")
+		if len(counts) < 2 {
+			err = &errortypes.ParseError{
+				errors.Newf("profile: Invalid bytecount '%s'", line),
+			}
+			return
+		}
+
+		rx, err = strconv.ParseUint(counts[0], 10, 64)
+		if err != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(err, "profile: Failed to parse rx bytecount"),
+			}
+			return
+		}
+
+		tx, err = strconv.ParseUint(counts[1], 10, 64)
+		if err != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(err, "profile: Failed to parse tx bytecount"),
+			}
+			return
+		}
+
+		break
+	}
+
+	_, _ = conn.Write([]byte("bytecount 0\nquit\n"))
+
+	return
+}
+
 func (o *Ovpn) watchCmd() {
 	defer func() {
 		panc := recover()
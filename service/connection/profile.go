@@ -3,6 +3,7 @@ package connection
 import (
 	"strings"
 
+	"github.com/pritunl/pritunl-client-electron/service/splittun"
 	"github.com/pritunl/pritunl-client-electron/service/sprofile"
 	"github.com/sirupsen/logrus"
 )
@@ -27,6 +28,14 @@ type Profile struct {
 	DisableGateway     bool        `json:"disable_gateway"`
 	DisableDns         bool        `json:"disable_dns"`
 	RestrictClient     bool        `json:"restrict_client"`
+	ProxyMode          bool        `json:"proxy_mode"`
+	WgUserspace        bool        `json:"wg_userspace"`
+	ProxyAddress       string      `json:"proxy_address"`
+	RouteIncludes      []string    `json:"route_includes"`
+	RouteExcludes      []string    `json:"route_excludes"`
+	SplitTunnelMode    string      `json:"split_tunnel_mode"`
+	SplitTunnelCgroups []string    `json:"split_tunnel_cgroups"`
+	KillSwitch         bool        `json:"kill_switch"`
 	ForceDns           bool        `json:"force_dns"`
 	SsoAuth            bool        `json:"sso_auth"`
 	ServerPublicKey    string      `json:"server_public_key"`
@@ -56,6 +65,20 @@ func (p *Profile) Fields() logrus.Fields {
 	}
 }
 
+func (p *Profile) IsDisableGateway() bool {
+	return p.DisableGateway || p.IsSplitInclude()
+}
+
+// IsSplitInclude returns true when the tunnel default route is only used
+// by the split tunnel applications
+func (p *Profile) IsSplitInclude() bool {
+	return p.SplitTunnelMode == splittun.Include
+}
+
+func (p *Profile) IsDisableDns() bool {
+	return p.DisableDns || p.ProxyMode
+}
+
 func (p *Profile) IsGeoSort() bool {
 	return p.GeoSort != ""
 }
@@ -117,6 +140,14 @@ func (p *Profile) ImportSystemProfile(sprfl *sprofile.Sprofile) {
 	p.DisableGateway = sprfl.DisableGateway
 	p.DisableDns = sprfl.DisableDns
 	p.RestrictClient = sprfl.RestrictClient
+	p.ProxyMode = sprfl.ProxyMode
+	p.WgUserspace = sprfl.WgUserspace
+	p.ProxyAddress = sprfl.ProxyAddress
+	p.RouteIncludes = sprfl.RouteIncludes
+	p.RouteExcludes = sprfl.RouteExcludes
+	p.SplitTunnelMode = sprfl.SplitTunnelMode
+	p.SplitTunnelCgroups = sprfl.SplitTunnelCgroups
+	p.KillSwitch = sprfl.KillSwitch
 	p.ForceDns = sprfl.ForceDns
 	p.SsoAuth = sprfl.SsoAuth
 	p.ServerPublicKey = serverPublicKey
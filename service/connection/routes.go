@@ -0,0 +1,274 @@
+package connection
+
+import (
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	RouteOverlap          = "route_overlap"
+	DefaultGatewayOverlap = "default_gateway"
+	DnsOverlap            = "dns"
+)
+
+type RouteEntry struct {
+	ProfileId  string `json:"profile_id"`
+	Network    string `json:"network"`
+	NetGateway bool   `json:"net_gateway"`
+	Iface      string `json:"iface"`
+}
+
+type RouteConflict struct {
+	Type           string `json:"type"`
+	ProfileId      string `json:"profile_id"`
+	OtherProfileId string `json:"other_profile_id"`
+	Network        string `json:"network"`
+	OtherNetwork   string `json:"other_network"`
+}
+
+type RouteConflictEvent struct {
+	Id        string           `json:"id"`
+	Rejected  bool             `json:"rejected"`
+	Conflicts []*RouteConflict `json:"conflicts"`
+}
+
+type RoutesData struct {
+	Routes     []*RouteEntry    `json:"routes"`
+	DnsServers []*RouteEntry    `json:"dns_servers"`
+	Conflicts  []*RouteConflict `json:"conflicts"`
+}
+
+type routeInfo struct {
+	id             string
+	prefixes       []netip.Prefix
+	defaultGateway bool
+	dnsServers     []string
+}
+
+func isDefaultPrefix(prefix netip.Prefix) bool {
+	if prefix.Bits() == 0 {
+		return true
+	}
+
+	return prefix.Bits() == 1
+}
+
+func (c *Connection) RouteEntries() (entries []*RouteEntry) {
+	entries = []*RouteEntry{}
+
+	excludes := map[string]bool{}
+	for _, network := range c.Profile.RouteExcludes {
+		excludes[network] = true
+	}
+
+	routes := []*Route{}
+	routes = append(routes, c.Data.Routes...)
+	routes = append(routes, c.Data.Routes6...)
+
+	for _, route := range routes {
+		if route == nil || route.Network == "" {
+			continue
+		}
+
+		entries = append(entries, &RouteEntry{
+			ProfileId:  c.Id,
+			Network:    route.Network,
+			NetGateway: route.NetGateway || excludes[route.Network],
+			Iface:      c.Data.Iface,
+		})
+	}
+
+	for _, network := range c.Profile.RouteIncludes {
+		entries = append(entries, &RouteEntry{
+			ProfileId: c.Id,
+			Network:   network,
+			Iface:     c.Data.Iface,
+		})
+	}
+
+	return
+}
+
+func (c *Connection) routeInfo() (info *routeInfo) {
+	info = &routeInfo{
+		id: c.Id,
+	}
+
+	if c.Profile.ProxyMode {
+		return
+	}
+
+	for _, entry := range c.RouteEntries() {
+		if entry.NetGateway {
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(entry.Network)
+		if err != nil {
+			continue
+		}
+		prefix = prefix.Masked()
+
+		if isDefaultPrefix(prefix) {
+			if !c.Profile.IsDisableGateway() {
+				info.defaultGateway = true
+			}
+			continue
+		}
+
+		info.prefixes = append(info.prefixes, prefix)
+	}
+
+	if c.Profile.Mode != WgMode && !c.Profile.IsDisableGateway() &&
+		strings.Contains(c.Profile.Data, "redirect-gateway") {
+
+		info.defaultGateway = true
+	}
+
+	if !c.Profile.IsDisableDns() {
+		info.dnsServers = c.Data.DnsServers
+	}
+
+	return
+}
+
+func routeConflicts(info *routeInfo, others []*routeInfo) (
+	conflicts []*RouteConflict) {
+
+	conflicts = []*RouteConflict{}
+
+	for _, other := range others {
+		if other.id == info.id {
+			continue
+		}
+
+		if info.defaultGateway && other.defaultGateway {
+			conflicts = append(conflicts, &RouteConflict{
+				Type:           DefaultGatewayOverlap,
+				ProfileId:      info.id,
+				OtherProfileId: other.id,
+			})
+		}
+
+		for _, prefix := range info.prefixes {
+			for _, otherPrefix := range other.prefixes {
+				if !prefix.Overlaps(otherPrefix) {
+					continue
+				}
+
+				conflicts = append(conflicts, &RouteConflict{
+					Type:           RouteOverlap,
+					ProfileId:      info.id,
+					OtherProfileId: other.id,
+					Network:        prefix.String(),
+					OtherNetwork:   otherPrefix.String(),
+				})
+			}
+		}
+
+		if len(info.dnsServers) > 0 && len(other.dnsServers) > 0 &&
+			strings.Join(info.dnsServers, ",") !=
+				strings.Join(other.dnsServers, ",") {
+
+			conflicts = append(conflicts, &RouteConflict{
+				Type:           DnsOverlap,
+				ProfileId:      info.id,
+				OtherProfileId: other.id,
+				Network:        strings.Join(info.dnsServers, ","),
+				OtherNetwork:   strings.Join(other.dnsServers, ","),
+			})
+		}
+	}
+
+	return
+}
+
+func (c *Connection) CheckRouteConflicts() (err error) {
+	info := c.routeInfo()
+
+	others := []*routeInfo{}
+	for _, conn := range GlobalStore.GetAll() {
+		if conn == c || conn.Id == c.Id {
+			continue
+		}
+		others = append(others, conn.routeInfo())
+	}
+
+	conflicts := routeConflicts(info, others)
+	if len(conflicts) == 0 {
+		return
+	}
+
+	rejected := config.Config.RejectRouteConflicts
+
+	logrus.WithFields(c.Fields(logrus.Fields{
+		"conflicts": len(conflicts),
+		"rejected":  rejected,
+	})).Warn("connection: Route conflict with active profiles")
+
+	evt := &event.Event{
+		Type: "route_conflict",
+		Data: &RouteConflictEvent{
+			Id:        c.Id,
+			Rejected:  rejected,
+			Conflicts: conflicts,
+		},
+	}
+	evt.Init()
+
+	if rejected {
+		c.State.NoReconnect("route_conflict")
+
+		err = &errortypes.RequestError{
+			errors.New("connection: Route conflict with active profile"),
+		}
+		return
+	}
+
+	return
+}
+
+func (s *Store) GetRoutes() (data *RoutesData) {
+	data = &RoutesData{
+		Routes:     []*RouteEntry{},
+		DnsServers: []*RouteEntry{},
+		Conflicts:  []*RouteConflict{},
+	}
+
+	conns := s.GetAll()
+
+	prflIds := []string{}
+	for prflId := range conns {
+		prflIds = append(prflIds, prflId)
+	}
+	sort.Strings(prflIds)
+
+	infos := []*routeInfo{}
+	for _, prflId := range prflIds {
+		conn := conns[prflId]
+
+		data.Routes = append(data.Routes, conn.RouteEntries()...)
+
+		info := conn.routeInfo()
+		for _, server := range info.dnsServers {
+			data.DnsServers = append(data.DnsServers, &RouteEntry{
+				ProfileId: conn.Id,
+				Network:   server,
+				Iface:     conn.Data.Iface,
+			})
+		}
+
+		data.Conflicts = append(data.Conflicts,
+			routeConflicts(info, infos)...)
+		infos = append(infos, info)
+	}
+
+	return
+}
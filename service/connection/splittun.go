@@ -0,0 +1,38 @@
+package connection
+
+import (
+	"github.com/pritunl/pritunl-client-electron/service/splittun"
+	"github.com/sirupsen/logrus"
+)
+
+func (c *Connection) SplitTunnelEnabled() bool {
+	return splittun.Supported() && !c.Profile.ProxyMode &&
+		(c.Profile.SplitTunnelMode == splittun.Include ||
+			c.Profile.SplitTunnelMode == splittun.Exclude)
+}
+
+func (c *Connection) SplitTunnelStart() {
+	if !c.SplitTunnelEnabled() {
+		return
+	}
+
+	err := splittun.Start(&splittun.Config{
+		Id:      c.Id,
+		Mode:    c.Profile.SplitTunnelMode,
+		Iface:   c.Data.Iface,
+		Cgroups: c.Profile.SplitTunnelCgroups,
+	})
+	if err != nil {
+		logrus.WithFields(c.Fields(logrus.Fields{
+			"error": err,
+		})).Error("connection: Failed to start split tunnel")
+	}
+}
+
+func (c *Connection) SplitTunnelStop() {
+	if !c.SplitTunnelEnabled() {
+		return
+	}
+
+	splittun.Stop(c.Id)
+}
@@ -1,10 +1,12 @@
 package connection
 
 import (
+	mathrand "math/rand"
 	"os"
 	"sync"
 	"time"
 
+	"github.com/pritunl/pritunl-client-electron/service/config"
 	"github.com/pritunl/pritunl-client-electron/service/utils"
 	"github.com/sirupsen/logrus"
 )
@@ -20,6 +22,8 @@ type State struct {
 	delay              bool
 	interactive        bool
 	noReconnect        bool
+	noReconnectReason  string
+	reconnectAttempts  int
 	closed             bool
 	systemInteractive  bool
 	closeWaiters       []chan bool
@@ -35,6 +39,7 @@ func (s *State) Fields() logrus.Fields {
 		"state_deadline":           s.deadline,
 		"state_delay":              s.delay,
 		"state_no_reconnect":       s.noReconnect,
+		"state_reconnect_attempts": s.reconnectAttempts,
 		"state_interactive":        s.interactive,
 		"state_system_interactive": s.systemInteractive,
 		"state_closed":             s.closed,
@@ -77,6 +82,33 @@ func (s *State) IsInteractive() bool {
 	return s.interactive || s.systemInteractive
 }
 
+func (s *State) ResetReconnect() {
+	s.reconnectAttempts = 0
+}
+
+func (s *State) ReconnectBackoff() (delay time.Duration) {
+	baseDelay := time.Duration(config.Config.ReconnectDelay) * time.Second
+	if baseDelay <= 0 {
+		baseDelay = DefaultReconnectDelay
+	}
+	maxDelay := time.Duration(config.Config.ReconnectMaxDelay) * time.Second
+	if maxDelay <= 0 {
+		maxDelay = DefaultReconnectMaxDelay
+	}
+
+	delay = baseDelay
+	for i := 1; i < s.reconnectAttempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+
+	delay = delay/2 + time.Duration(mathrand.Int63n(int64(delay/2)+1))
+
+	return
+}
+
 func (s *State) NoReconnect(reason string) {
 	if s.noReconnect {
 		return
@@ -85,6 +117,7 @@ func (s *State) NoReconnect(reason string) {
 		"reason": reason,
 	})).Info("connection: Stopping reconnect")
 	s.noReconnect = true
+	s.noReconnectReason = reason
 }
 
 func (s *State) stopWatch() {
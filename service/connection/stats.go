@@ -0,0 +1,131 @@
+package connection
+
+import (
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	StatsInterval = 5 * time.Second
+)
+
+type Stats struct {
+	conn       *Connection `json:"-"`
+	lock       sync.Mutex  `json:"-"`
+	lastUpdate time.Time   `json:"-"`
+	Id         string      `json:"id"`
+	RxBytes    uint64      `json:"rx_bytes"`
+	TxBytes    uint64      `json:"tx_bytes"`
+	RxRate     uint64      `json:"rx_rate"`
+	TxRate     uint64      `json:"tx_rate"`
+	Timestamp  int64       `json:"timestamp"`
+}
+
+func (s *Stats) Fields() logrus.Fields {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return logrus.Fields{
+		"stats_rx_bytes": s.RxBytes,
+		"stats_tx_bytes": s.TxBytes,
+	}
+}
+
+func (s *Stats) Copy() (stats *Stats) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	stats = &Stats{
+		Id:        s.Id,
+		RxBytes:   s.RxBytes,
+		TxBytes:   s.TxBytes,
+		RxRate:    s.RxRate,
+		TxRate:    s.TxRate,
+		Timestamp: s.Timestamp,
+	}
+
+	return
+}
+
+func (s *Stats) Update(prov Provider) (err error) {
+	rx, tx, err := prov.GetTransfer()
+	if err != nil {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	if !s.lastUpdate.IsZero() {
+		elapsed := now.Sub(s.lastUpdate).Seconds()
+		if elapsed > 0 {
+			if rx >= s.RxBytes {
+				s.RxRate = uint64(float64(rx-s.RxBytes) / elapsed)
+			} else {
+				s.RxRate = 0
+			}
+			if tx >= s.TxBytes {
+				s.TxRate = uint64(float64(tx-s.TxBytes) / elapsed)
+			} else {
+				s.TxRate = 0
+			}
+		}
+	}
+
+	s.RxBytes = rx
+	s.TxBytes = tx
+	s.Timestamp = now.Unix()
+	s.lastUpdate = now
+
+	return
+}
+
+func (s *Stats) SendEvent() {
+	evt := &event.Event{
+		Type: "stats",
+		Data: s.Copy(),
+	}
+	evt.Init()
+}
+
+func (s *Stats) watch(prov Provider) {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(s.conn.Fields(logrus.Fields{
+				"trace": string(debug.Stack()),
+				"panic": panc,
+			})).Error("stats: Watch stats panic")
+		}
+	}()
+
+	for {
+		time.Sleep(StatsInterval)
+		if s.conn.State.IsStopFast() {
+			return
+		}
+
+		if s.conn.Data.Status != Connected {
+			continue
+		}
+
+		err := s.Update(prov)
+		if err != nil {
+			logrus.WithFields(s.conn.Fields(logrus.Fields{
+				"error": err,
+			})).Warn("stats: Failed to update connection stats")
+			continue
+		}
+
+		if s.conn.State.IsStopFast() {
+			return
+		}
+
+		s.SendEvent()
+	}
+}
@@ -119,7 +119,7 @@ func (s *Store) Add(prflId string, conn *Connection) {
 
 	s.lock.RLock()
 	c := s.conns[prflId]
-	if c == nil {
+	if c == nil || c == conn {
 		s.conns[prflId] = conn
 		s.lock.RUnlock()
 		return
@@ -153,6 +153,9 @@ func (s *Store) Remove(prflId string, conn *Connection) {
 	c := s.conns[prflId]
 	if c == conn {
 		delete(s.conns, prflId)
+		if conn.Profile.SystemProfile {
+			queueSystemSync(prflId)
+		}
 	} else {
 		logrus.WithFields(c.Fields(nil)).Error(
 			"connection: Attempting to delete active connection")
@@ -197,6 +200,20 @@ func (s *Store) GetData(prflId string) (prfl *Data) {
 	return
 }
 
+func (s *Store) GetStats(prflId string) (stats *Stats) {
+	prflId = utils.FilterStrN(prflId, 128)
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	conn := s.conns[prflId]
+	if conn != nil {
+		stats = conn.Stats.Copy()
+	}
+
+	return
+}
+
 func (s *Store) SetAuthConnect(prflId string) {
 	s.conditionsLock.Lock()
 	defer s.conditionsLock.Unlock()
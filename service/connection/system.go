@@ -2,19 +2,50 @@ package connection
 
 import (
 	"runtime/debug"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/platform"
 	"github.com/pritunl/pritunl-client-electron/service/sprofile"
 	"github.com/pritunl/pritunl-client-electron/service/update"
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	systemSyncDelay  = 500 * time.Millisecond
+	systemResyncRate = 60 * time.Second
+)
+
 var (
 	sprofileShutown = false
+	systemSyncs     = make(chan string, 64)
+	exhausted       = map[string]bool{}
+	exhaustedLock   = sync.Mutex{}
 )
 
+func setExhausted(prflId string) {
+	exhaustedLock.Lock()
+	exhausted[prflId] = true
+	exhaustedLock.Unlock()
+}
+
+func isExhausted(prflId string) bool {
+	exhaustedLock.Lock()
+	defer exhaustedLock.Unlock()
+	return exhausted[prflId]
+}
+
+// ClearExhausted allows a system profile that exceeded the reconnect
+// attempts to be started by the sync again
+func ClearExhausted(prflId string) {
+	exhaustedLock.Lock()
+	delete(exhausted, prflId)
+	exhaustedLock.Unlock()
+}
+
 func ImportSystemProfile(sprfl *sprofile.Sprofile) (
 	conn *Connection, err error) {
 
@@ -32,7 +63,9 @@ func ImportSystemProfile(sprfl *sprofile.Sprofile) (
 	return
 }
 
-func SyncSystemProfiles() (err error) {
+// SyncSystemProfiles starts and stops connections to match the system
+// profile states, all profiles are synced when no IDs are given
+func SyncSystemProfiles(prflIds ...string) (err error) {
 	sprfls, err := sprofile.GetAll()
 	if err != nil {
 		return
@@ -43,11 +76,30 @@ func SyncSystemProfiles() (err error) {
 	update := false
 	waiter := sync.WaitGroup{}
 
+	var filter map[string]bool
+	if len(prflIds) > 0 {
+		filter = map[string]bool{}
+		for _, prflId := range prflIds {
+			filter[prflId] = true
+		}
+	}
+
 	for _, sPrfl := range sprfls {
+		if filter != nil {
+			if !filter[sPrfl.Id] {
+				continue
+			}
+			delete(filter, sPrfl.Id)
+		}
+
 		conn := conns[sPrfl.Id]
 
 		if sPrfl.State {
 			if conn == nil {
+				if isExhausted(sPrfl.Id) {
+					continue
+				}
+
 				conn, err = ImportSystemProfile(sPrfl)
 				if err != nil {
 					return
@@ -158,6 +210,22 @@ func SyncSystemProfiles() (err error) {
 		}
 	}
 
+	// Stop connections of removed system profiles
+	for prflId := range filter {
+		conn := conns[prflId]
+		if conn == nil || !conn.Profile.SystemProfile {
+			continue
+		}
+
+		update = true
+		waiter.Add(1)
+
+		go func() {
+			conn.Stop()
+			waiter.Done()
+		}()
+	}
+
 	waiter.Wait()
 
 	if update {
@@ -185,6 +253,47 @@ func SyncSystemProfiles() (err error) {
 	return
 }
 
+func queueSystemSync(prflId string) {
+	select {
+	case systemSyncs <- prflId:
+	default:
+	}
+}
+
+func syncSystemProfiles(prflIds ...string) {
+	if IsSuspended() {
+		return
+	}
+
+	err := SyncSystemProfiles(prflIds...)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("profile: Failed to sync system profiles")
+	}
+}
+
+func reloadSystemProfiles(pths map[string]bool) (prflIds []string) {
+	prflIds = []string{}
+
+	for pth := range pths {
+		prflId, err := sprofile.ReloadFile(pth)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"path":  pth,
+				"error": err,
+			}).Error("profile: Failed to reload system profile")
+			continue
+		}
+
+		if prflId != "" {
+			prflIds = append(prflIds, prflId)
+		}
+	}
+
+	return
+}
+
 func watchSystemProfiles() {
 	defer func() {
 		panc := recover()
@@ -201,6 +310,112 @@ func watchSystemProfiles() {
 	time.Sleep(1 * time.Second)
 	sprofile.Reload(true)
 
+	prflsPath := sprofile.GetPath()
+	err := platform.MkdirSecure(prflsPath)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("profile: Failed to create system profiles directory")
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err == nil {
+		err = watcher.Add(prflsPath)
+		if err != nil {
+			_ = watcher.Close()
+		}
+	}
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("profile: Failed to watch system profiles directory")
+
+		pollSystemProfiles()
+		return
+	}
+	defer watcher.Close()
+
+	syncSystemProfiles()
+
+	pendingPths := map[string]bool{}
+	pendingIds := map[string]bool{}
+	pending := time.NewTimer(systemSyncDelay)
+	pending.Stop()
+	resync := time.NewTicker(systemResyncRate)
+	defer resync.Stop()
+
+	for {
+		select {
+		case evt, ok := <-watcher.Events:
+			if !ok {
+				pollSystemProfiles()
+				return
+			}
+
+			if !strings.HasSuffix(evt.Name, ".conf") ||
+				evt.Op == fsnotify.Chmod {
+
+				continue
+			}
+
+			pendingPths[evt.Name] = true
+			pending.Reset(systemSyncDelay)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				pollSystemProfiles()
+				return
+			}
+
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("profile: System profiles watcher error")
+		case prflId := <-sprofile.Changes():
+			pendingIds[prflId] = true
+			pending.Reset(systemSyncDelay)
+		case prflId := <-systemSyncs:
+			pendingIds[prflId] = true
+			pending.Reset(systemSyncDelay)
+		case <-pending.C:
+			for _, prflId := range reloadSystemProfiles(pendingPths) {
+				pendingIds[prflId] = true
+			}
+			pendingPths = map[string]bool{}
+
+			prflIds := []string{}
+			all := false
+			for prflId := range pendingIds {
+				if prflId == "" {
+					all = true
+				}
+				prflIds = append(prflIds, prflId)
+			}
+			pendingIds = map[string]bool{}
+
+			if all {
+				syncSystemProfiles()
+			} else if len(prflIds) > 0 {
+				syncSystemProfiles(prflIds...)
+			}
+		case <-resync.C:
+			if Shutdown {
+				return
+			}
+
+			if !GlobalStore.IsActive() {
+				_ = update.Check()
+			}
+
+			syncSystemProfiles()
+		}
+
+		if Shutdown {
+			return
+		}
+	}
+}
+
+// pollSystemProfiles is used when the profiles directory cannot be watched
+func pollSystemProfiles() {
 	for {
 		time.Sleep(2 * time.Second)
 
@@ -208,16 +423,15 @@ func watchSystemProfiles() {
 			return
 		}
 
+		if IsSuspended() {
+			continue
+		}
+
 		if !GlobalStore.IsActive() {
 			_ = update.Check()
 		}
 
-		err := SyncSystemProfiles()
-		if err != nil {
-			logrus.WithFields(logrus.Fields{
-				"error": err,
-			}).Error("profile: Failed to sync system profiles")
-		}
+		syncSystemProfiles()
 	}
 }
 
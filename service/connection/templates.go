@@ -10,7 +10,11 @@ const (
 Address = {{.Address}}
 PrivateKey = {{.PrivateKey}}{{if .HasMtu}}
 MTU = {{.Mtu}}{{end}}{{if .HasDns}}
-DNS = {{.DnsServers}}{{end}}
+DNS = {{.DnsServers}}{{end}}{{range .BypassIps}}
+PostUp = ip rule add to {{.}} table main
+PreDown = ip rule del to {{.}} table main{{end}}{{if .TableOff}}
+Table = off{{range .Routes}}
+PostUp = ip route add {{.}} dev %i{{end}}{{end}}
 
 [Peer]
 PublicKey = {{.PublicKey}}
@@ -34,4 +38,7 @@ type WgConfData struct {
 	PublicKey  string
 	AllowedIps string
 	Endpoint   string
+	BypassIps  []string
+	TableOff   bool
+	Routes     []string
 }
@@ -12,6 +12,9 @@ import (
 	"time"
 
 	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/pritunl-client-electron/service/dns"
+	"github.com/pritunl/pritunl-client-electron/service/killswitch"
+	"github.com/pritunl/pritunl-client-electron/service/splittun"
 	"github.com/pritunl/pritunl-client-electron/service/utils"
 	"github.com/sirupsen/logrus"
 )
@@ -20,6 +23,8 @@ var (
 	ipReg             = regexp.MustCompile(`(25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)(\.(25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)){3}`)
 	profileReg        = regexp.MustCompile(`[^a-z0-9_\- ]+`)
 	restartLock       sync.Mutex
+	suspended         bool
+	suspendedLock     sync.Mutex
 	cachedPublicAddr4 = ""
 	cachedPublicAddr6 = ""
 )
@@ -218,7 +223,99 @@ func RestartProfiles() (err error) {
 	return
 }
 
+func IsSuspended() bool {
+	suspendedLock.Lock()
+	defer suspendedLock.Unlock()
+	return suspended
+}
+
+func setSuspended(state bool) {
+	suspendedLock.Lock()
+	suspended = state
+	suspendedLock.Unlock()
+}
+
+// SuspendProfiles stops all connections before the system sleeps and
+// returns the non-system profiles to start on resume, system profiles are
+// not synced until resumed
+func SuspendProfiles() (prfls []*Profile) {
+	restartLock.Lock()
+	defer restartLock.Unlock()
+
+	setSuspended(true)
+
+	conns := GlobalStore.GetAll()
+	prfls = []*Profile{}
+
+	for _, conn := range conns {
+		if conn.State.IsReconnect() && !conn.Profile.SystemProfile {
+			prfls = append(prfls, conn.Profile)
+		}
+		conn.StopBackground()
+	}
+
+	for _, conn := range conns {
+		conn.StopWait()
+	}
+
+	return
+}
+
+func ResumeProfiles(prfls []*Profile) {
+	restartLock.Lock()
+	defer restartLock.Unlock()
+
+	setSuspended(false)
+
+	exhaustedLock.Lock()
+	exhausted = map[string]bool{}
+	exhaustedLock.Unlock()
+
+	queueSystemSync("")
+
+	for _, prfl := range prfls {
+		go func(prfl *Profile) {
+			defer func() {
+				panc := recover()
+				if panc != nil {
+					logrus.WithFields(logrus.Fields{
+						"trace": string(debug.Stack()),
+						"panic": panc,
+					}).Error("profile: Profile resume panic")
+				}
+			}()
+
+			newConn, e := NewConnection(prfl)
+			if e != nil {
+				logrus.WithFields(logrus.Fields{
+					"profile_id": prfl.Id,
+					"error":      e,
+				}).Error("profile: Failed to init connection in resume")
+				return
+			}
+
+			e = newConn.Start(Options{})
+			if e != nil {
+				logrus.WithFields(logrus.Fields{
+					"profile_id": prfl.Id,
+					"error":      e,
+				}).Error("profile: Failed to start connection in resume")
+				return
+			}
+		}(prfl)
+	}
+}
+
 func Clean() (err error) {
+	err = killswitch.Clean()
+	if err != nil {
+		return
+	}
+
+	splittun.Clean()
+
+	dns.Clean()
+
 	if runtime.GOOS != "windows" {
 		return
 	}
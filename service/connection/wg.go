@@ -16,10 +16,15 @@ import (
 
 	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/dns"
 	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/log"
+	"github.com/pritunl/pritunl-client-electron/service/metrics"
 	"github.com/pritunl/pritunl-client-electron/service/network"
 	"github.com/pritunl/pritunl-client-electron/service/platform"
+	"github.com/pritunl/pritunl-client-electron/service/splittun"
 	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/pritunl/pritunl-client-electron/service/wglink"
 	"github.com/sirupsen/logrus"
 )
 
@@ -36,6 +41,9 @@ type Wg struct {
 	wgConfPath    string
 	wgConfPath2   string
 	connected     bool
+	native        bool
+	usp           *WgUsp
+	linkConf      *wglink.Config
 	lastHandshake int
 	bashPath      string
 	publicKey     string
@@ -74,6 +82,7 @@ func (w *Wg) Fields() logrus.Fields {
 		"wg_conf_path":      w.wgConfPath,
 		"wg_conf_path2":     w.wgConfPath2,
 		"wg_connected":      w.connected,
+		"wg_native":         w.native,
 		"wg_last_handshake": w.lastHandshake,
 		"wg_pub_key":        w.publicKey != "",
 		"wg_priv_key":       w.privateKey != "",
@@ -99,6 +108,15 @@ func (w *Wg) GetReqPrefix() string {
 }
 
 func (w *Wg) generateKey() (err error) {
+	if w.wgPath == "" {
+		w.privateKey, w.publicKey, err = utils.GenerateWgKey()
+		if err != nil {
+			return
+		}
+
+		return
+	}
+
 	privateKey, err := utils.ExecOutput(w.wgPath, "genkey")
 	if err != nil {
 		err = &errortypes.ExecError{
@@ -159,12 +177,15 @@ func (w *Wg) Connect(data *ConnData) (err error) {
 	}
 	w.conn.Data.Iface = iface
 
+	w.conn.KillSwitchAllow(iface, data.Configuration.Hostname,
+		data.Configuration.Hostname6)
+
 	if w.conn.State.IsStop() {
 		w.conn.State.Close()
 		return
 	}
 
-	if w.conn.Profile.DisableGateway {
+	if w.disableGateway() {
 		routes := []*Route{}
 		for _, route := range data.Configuration.Routes {
 			if route.Network == "0.0.0.0/0" {
@@ -184,6 +205,25 @@ func (w *Wg) Connect(data *ConnData) (err error) {
 		data.Configuration.Routes6 = routes6
 	}
 
+	w.conn.Data.DnsServers = data.Configuration.DnsServers
+	w.conn.Data.Routes = data.Configuration.Routes
+	w.conn.Data.Routes6 = data.Configuration.Routes6
+
+	err = w.conn.CheckRouteConflicts()
+	if err != nil {
+		w.conn.State.Close()
+		return
+	}
+
+	pathMtu := w.conn.DiscoverPathMtu(data.Configuration.Hostname)
+	if pathMtu != 0 {
+		data.Configuration.Mtu = wgMtu(pathMtu, data.Configuration.Mtu)
+	}
+	w.conn.Data.Mtu = data.Configuration.Mtu
+	if w.conn.Data.Mtu == 0 {
+		w.conn.Data.Mtu = mtuWgDefault
+	}
+
 	err = w.writeWgConf(data.Configuration)
 	if err != nil {
 		return
@@ -211,6 +251,10 @@ func (w *Wg) Connect(data *ConnData) (err error) {
 		return
 	}
 
+	if w.usp == nil || !w.usp.IsNetstack() {
+		w.conn.SplitTunnelStart()
+	}
+
 	w.conn.Data.ValidateAuthToken()
 
 	logrus.WithFields(w.conn.Fields(logrus.Fields{
@@ -265,11 +309,12 @@ func (w *Wg) WatchConnection() (err error) {
 			return
 		}
 
-		if w.lastHandshake != 0 {
+		if w.getHandshake() != 0 {
 			w.connected = true
 			w.conn.Data.Status = Connected
 			w.conn.Data.Timestamp = time.Now().Unix() - 3
 			w.conn.Data.UpdateEvent()
+			w.conn.Client.Connected()
 			break
 		}
 
@@ -281,8 +326,12 @@ func (w *Wg) WatchConnection() (err error) {
 		return
 	}
 
-	if w.lastHandshake == 0 {
+	if w.getHandshake() == 0 {
 		w.conn.Data.SendProfileEvent("handshake_timeout")
+		w.conn.PushHistory(&log.HistoryEntry{
+			Event:  log.HistoryHandshakeTimeout,
+			Remote: w.conn.Data.ServerAddr,
+		})
 
 		w.conn.State.Close()
 		return
@@ -290,10 +339,15 @@ func (w *Wg) WatchConnection() (err error) {
 
 	if !w.conn.Profile.DisableDns && w.conn.Data.DnsServers != nil &&
 		len(w.conn.Data.DnsServers) > 0 && runtime.GOOS == "darwin" &&
-		!config.Config.DisableWgDns {
+		!config.Config.DisableWgDns && w.usp == nil {
+
+		dnsServers := w.conn.Data.DnsServers
+		if w.linkConf != nil && len(w.linkConf.DnsServers) > 0 {
+			dnsServers = w.linkConf.DnsServers
+		}
 
 		err := utils.SetScutilDns(w.conn.Id,
-			w.conn.Data.DnsServers, w.conn.Data.DnsServers)
+			dnsServers, w.conn.Data.DnsServers)
 		if err != nil {
 			logrus.WithFields(w.conn.Fields(logrus.Fields{
 				"error": err,
@@ -335,6 +389,8 @@ func (w *Wg) WatchConnection() (err error) {
 				break
 			}
 
+			metrics.ProfileKeepaliveFailure(w.conn.Id)
+
 			if time.Since(lastRetryLogged) > 30*time.Minute {
 				lastRetryLogged = time.Now()
 				logrus.WithFields(w.conn.Fields(logrus.Fields{
@@ -350,6 +406,8 @@ func (w *Wg) WatchConnection() (err error) {
 			time.Sleep(1 * time.Second)
 		}
 		if err != nil {
+			metrics.ProfileKeepaliveFailure(w.conn.Id)
+
 			logrus.WithFields(w.conn.Fields(logrus.Fields{
 				"error": err,
 			})).Error("connection: Keepalive failed")
@@ -370,10 +428,41 @@ func (w *Wg) WatchConnection() (err error) {
 			w.conn.State.Close()
 			return
 		}
+
+		err = w.updateHandshake()
+		if err != nil {
+			logrus.WithFields(w.conn.Fields(logrus.Fields{
+				"error": err,
+			})).Warn("connection: Failed to update handshake status")
+			err = nil
+		}
 	}
 }
 
 func (w *Wg) updateHandshake() (err error) {
+	handshake, err := w.readHandshake()
+	if err != nil {
+		return
+	}
+
+	w.lock.Lock()
+	w.lastHandshake = handshake
+	w.lock.Unlock()
+
+	if handshake != 0 {
+		metrics.ProfileHandshake(w.conn.Id, int64(handshake))
+	}
+
+	return
+}
+
+func (w *Wg) getHandshake() int {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.lastHandshake
+}
+
+func (w *Wg) readHandshake() (handshake int, err error) {
 	iface := ""
 	if runtime.GOOS == "darwin" {
 		iface = w.conn.Data.WgTunIface
@@ -381,6 +470,32 @@ func (w *Wg) updateHandshake() (err error) {
 		iface = w.conn.Data.Iface
 	}
 
+	if w.usp != nil {
+		lastHandshake, _, _, e := w.usp.getPeer()
+		if e != nil {
+			err = e
+			return
+		}
+
+		handshake = int(lastHandshake)
+		return
+	}
+
+	if w.native {
+		peer, e := wglink.GetPeer(iface, w.serverPubKey)
+		if e != nil {
+			if _, ok := e.(*errortypes.NotFoundError); !ok {
+				err = e
+				return
+			}
+
+			return
+		}
+
+		handshake = int(peer.LastHandshake)
+		return
+	}
+
 	output, err := utils.ExecCombinedOutputLogged(
 		[]string{
 			"No such device",
@@ -405,12 +520,77 @@ func (w *Wg) updateHandshake() (err error) {
 				continue
 			}
 
-			w.lastHandshake = lastHandshake
+			handshake = lastHandshake
+			return
+		}
+	}
+
+	return
+}
+
+func (w *Wg) GetTransfer() (rx, tx uint64, err error) {
+	iface := ""
+	if runtime.GOOS == "darwin" {
+		iface = w.conn.Data.WgTunIface
+	} else {
+		iface = w.conn.Data.Iface
+	}
+
+	if iface == "" {
+		return
+	}
+
+	if w.native {
+		peer, e := wglink.GetPeer(iface, w.serverPubKey)
+		if e != nil {
+			err = e
+			return
+		}
+
+		rx = peer.RxBytes
+		tx = peer.TxBytes
+		return
+	}
+
+	output, err := utils.ExecCombinedOutputLogged(
+		[]string{
+			"No such device",
+			"access interface",
+		},
+		w.wgPath, "show", iface,
+		"transfer",
+	)
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+
+		if fields[0] == w.serverPubKey {
+			rx, err = strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				err = &errortypes.ParseError{
+					errors.Wrap(err, "wg: Failed to parse rx transfer"),
+				}
+				return
+			}
+
+			tx, err = strconv.ParseUint(fields[2], 10, 64)
+			if err != nil {
+				err = &errortypes.ParseError{
+					errors.Wrap(err, "wg: Failed to parse tx transfer"),
+				}
+				return
+			}
+
 			return
 		}
 	}
 
-	w.lastHandshake = 0
 	return
 }
 
@@ -432,7 +612,13 @@ func (w *Wg) ping() (data *PingData, final bool, err error) {
 		return
 	}
 
-	res, err := w.conn.Client.EncRequest("PUT", reqUrl, ciph, reqBx)
+	httpClient := clientInsecure
+	if w.usp != nil && w.usp.httpClient != nil {
+		httpClient = w.usp.httpClient
+	}
+
+	res, err := w.conn.Client.EncRequestClient(
+		httpClient, "PUT", reqUrl, ciph, reqBx)
 	if err != nil {
 		return
 	}
@@ -476,17 +662,25 @@ func (w *Wg) ping() (data *PingData, final bool, err error) {
 	return
 }
 
+// disableGateway returns true when the default route is removed from the
+// peer allowed ips
+func (w *Wg) disableGateway() bool {
+	return w.conn.Profile.DisableGateway ||
+		(w.conn.Profile.IsSplitInclude() && !splittun.Supported())
+}
+
 func (w *Wg) writeWgConf(data *WgConf) (err error) {
 	allowedIps := []string{}
+	excludeIps := []string{}
 	if data.Routes != nil {
 		for _, route := range data.Routes {
-			if w.conn.Profile.DisableGateway && route.Network == "0.0.0.0/0" {
+			if w.disableGateway() && route.Network == "0.0.0.0/0" {
+
 				continue
 			}
 
 			if route.NetGateway {
-				// TODO wireguard exclude
-				//allowedIps = append(allowedIps, "!"+route.Network)
+				excludeIps = append(excludeIps, route.Network)
 			} else {
 				allowedIps = append(allowedIps, route.Network)
 			}
@@ -494,30 +688,110 @@ func (w *Wg) writeWgConf(data *WgConf) (err error) {
 	}
 	if data.Routes6 != nil {
 		for _, route := range data.Routes6 {
-			if w.conn.Profile.DisableGateway && route.Network == "::/0" {
+			if w.disableGateway() && route.Network == "::/0" {
 				continue
 			}
 
 			if route.NetGateway {
-				// TODO wireguard exclude
-				//allowedIps = append(allowedIps, "!"+route.Network)
+				excludeIps = append(excludeIps, route.Network)
 			} else {
 				allowedIps = append(allowedIps, route.Network)
 			}
 		}
 	}
 
+	allowedIps = append(allowedIps, w.conn.Profile.RouteIncludes...)
+	excludeIps = append(excludeIps, w.conn.Profile.RouteExcludes...)
+
+	for _, endpointIp := range resolveHost(data.Hostname, true) {
+		if strings.Contains(endpointIp, ":") {
+			excludeIps = append(excludeIps, endpointIp+"/128")
+		} else {
+			excludeIps = append(excludeIps, endpointIp+"/32")
+		}
+	}
+
+	defaultRoute := false
+	defaultIps := []string{}
+	routeIps := []string{}
+	for _, allowedIp := range allowedIps {
+		if strings.HasSuffix(allowedIp, "/0") {
+			defaultRoute = true
+			defaultIps = append(defaultIps, allowedIp)
+		} else {
+			routeIps = append(routeIps, allowedIp)
+		}
+	}
+
+	// Split tunnel include mode keeps the default route in the peer
+	// allowed ips for the split tunnel table without routing the system
+	noDefault := w.conn.Profile.IsSplitInclude() && splittun.Supported()
+
+	bypassIps := []string{}
+	if defaultRoute && runtime.GOOS == "linux" {
+		// Default routes use fwmark policy routing, excluded networks
+		// are sent to the main table with bypass rules
+		if !noDefault {
+			bypassIps = excludeIps
+		}
+
+		allowedIps, err = utils.SubtractCidrs(routeIps, excludeIps)
+		if err != nil {
+			return
+		}
+		allowedIps = append(defaultIps, allowedIps...)
+	} else {
+		allowedIps, err = utils.SubtractCidrs(allowedIps, excludeIps)
+		if err != nil {
+			return
+		}
+	}
+
 	addr := data.Address
 	if data.Address6 != "" {
 		addr += "," + data.Address6
 	}
 
+	linkConf := &wglink.Config{
+		Iface:          w.conn.Data.Iface,
+		PrivateKey:     w.privateKey,
+		Mtu:            data.Mtu,
+		Addresses:      []string{data.Address},
+		PublicKey:      data.PublicKey,
+		Endpoint:       fmt.Sprintf("%s:%d", data.Hostname, data.Port),
+		AllowedIps:     allowedIps,
+		BypassIps:      bypassIps,
+		NoDefaultRoute: noDefault,
+	}
+
+	if data.Address6 != "" {
+		linkConf.Addresses = append(linkConf.Addresses, data.Address6)
+	}
+
+	dnsServers := data.DnsServers
+	if !w.conn.Profile.DisableDns && len(data.DnsServers) > 0 {
+		dnsServers = w.conn.DnsForward(data.DnsServers,
+			data.SearchDomains,
+			(defaultRoute && !noDefault) || len(data.SearchDomains) == 0)
+
+		linkConf.DnsServers = dnsServers
+		linkConf.SearchDomains = data.SearchDomains
+	}
+
+	w.linkConf = linkConf
+
 	templData := WgConfData{
 		Address:    addr,
 		PrivateKey: w.privateKey,
 		PublicKey:  data.PublicKey,
 		AllowedIps: strings.Join(allowedIps, ","),
 		Endpoint:   fmt.Sprintf("%s:%d", data.Hostname, data.Port),
+		BypassIps:  bypassIps,
+	}
+
+	if noDefault {
+		templData.TableOff = true
+		templData.Routes = routeIps
 	}
 
 	if data.Mtu != 0 {
@@ -526,11 +800,11 @@ func (w *Wg) writeWgConf(data *WgConf) (err error) {
 	}
 
 	if !w.conn.Profile.DisableDns && data.DnsServers != nil &&
-		len(data.DnsServers) > 0 &&
+		len(data.DnsServers) > 0 && runtime.GOOS != "linux" &&
 		(runtime.GOOS != "darwin" || config.Config.DisableWgDns) {
 
 		templData.HasDns = true
-		templData.DnsServers = strings.Join(data.DnsServers, ",")
+		templData.DnsServers = strings.Join(dnsServers, ",")
 	}
 
 	output := &bytes.Buffer{}
@@ -609,9 +883,20 @@ func (w *Wg) confWg(data *WgConf) (err error) {
 	w.conn.Data.WebNoSsl = data.WebNoSsl
 	w.conn.Data.DnsServers = data.DnsServers
 	w.conn.Data.SearchDomains = data.SearchDomains
+	w.conn.Data.Routes = data.Routes
+	w.conn.Data.Routes6 = data.Routes6
 
 	w.serverPubKey = data.PublicKey
 
+	if w.usp != nil {
+		err = w.usp.conf(w.linkConf)
+		if err != nil {
+			return
+		}
+
+		return
+	}
+
 	switch runtime.GOOS {
 	case "darwin":
 		err = w.confWgMac()
@@ -636,6 +921,24 @@ func (w *Wg) confWgLinux() (err error) {
 	w.lock.Lock()
 	defer w.lock.Unlock()
 
+	if w.linkConf != nil && !config.Config.DisableWgNative &&
+		wglink.Supported() {
+
+		err = wglink.Up(w.linkConf)
+		if err == nil {
+			w.native = true
+			return
+		}
+
+		logrus.WithFields(w.conn.Fields(logrus.Fields{
+			"error": err,
+		})).Warn("wg: Failed to configure native interface, " +
+			"falling back to wg-quick")
+
+		_ = wglink.Down(w.conn.Data.Iface)
+		err = nil
+	}
+
 	for i := 0; i < 3; i++ {
 		_, _ = utils.ExecCombinedOutput(
 			w.wgQuickPath, "down", w.conn.Data.Iface,
@@ -661,6 +964,19 @@ func (w *Wg) confWgLinux() (err error) {
 		return
 	}
 
+	if w.linkConf != nil && len(w.linkConf.DnsServers) > 0 {
+		err = dns.Set(&dns.Config{
+			Iface:   w.conn.Data.Iface,
+			Servers: w.linkConf.DnsServers,
+			Domains: w.linkConf.SearchDomains,
+			DefaultRoute: w.conn.routeInfo().defaultGateway ||
+				len(w.linkConf.SearchDomains) == 0,
+		})
+		if err != nil {
+			return
+		}
+	}
+
 	return
 }
 
@@ -767,7 +1083,21 @@ func (w *Wg) clearWgLinux() {
 	w.lock.Lock()
 	defer w.lock.Unlock()
 
+	if w.native {
+		w.native = false
+
+		err := wglink.Down(w.conn.Data.Iface)
+		if err != nil {
+			logrus.WithFields(w.conn.Fields(logrus.Fields{
+				"error": err,
+			})).Error("wg: Failed to remove native interface")
+		}
+		return
+	}
+
 	if w.conn.Data.Iface != "" {
+		dns.Clear(w.conn.Data.Iface)
+
 		utils.ExecCombinedOutputLogged(
 			[]string{
 				"does not exist",
@@ -813,6 +1143,12 @@ func (w *Wg) clearWgWin() {
 }
 
 func (w *Wg) clearWg() {
+	if w.usp != nil {
+		w.usp.clear()
+		network.InterfaceRelease(w.conn.Data.Iface)
+		return
+	}
+
 	switch runtime.GOOS {
 	case "linux":
 		w.clearWgLinux()
@@ -829,7 +1165,11 @@ func (w *Wg) clearWg() {
 }
 
 func (w *Wg) Disconnect() {
+	w.conn.SplitTunnelStop()
+
 	w.clearWg()
 
+	metrics.ProfileHandshake(w.conn.Id, 0)
+
 	return
 }
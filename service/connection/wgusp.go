@@ -0,0 +1,421 @@
+package connection
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/proxy"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/pritunl/pritunl-client-electron/service/wglink"
+	"github.com/sirupsen/logrus"
+	"golang.zx2c4.com/wireguard/conn"
+	"golang.zx2c4.com/wireguard/device"
+	"golang.zx2c4.com/wireguard/tun"
+	"golang.zx2c4.com/wireguard/tun/netstack"
+)
+
+const (
+	wgUspDefaultMtu = 1420
+)
+
+type WgUsp struct {
+	conn       *Connection
+	lock       sync.Mutex
+	device     *device.Device
+	tnet       *netstack.Net
+	proxy      *proxy.Proxy
+	httpClient *http.Client
+	netstack   bool
+}
+
+func (u *WgUsp) Fields() logrus.Fields {
+	return logrus.Fields{
+		"wgusp_active":   u.device != nil,
+		"wgusp_netstack": u.netstack,
+	}
+}
+
+func (u *WgUsp) GetPublicKey() string {
+	return u.conn.Wg.GetPublicKey()
+}
+
+func (u *WgUsp) GetReqPrefix() string {
+	return u.conn.Wg.GetReqPrefix()
+}
+
+func (u *WgUsp) Start() (err error) {
+	u.conn.Wg.usp = u
+
+	err = u.conn.Client.Start(u)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (u *WgUsp) PreConnect() (err error) {
+	privateKey, publicKey, err := utils.GenerateWgKey()
+	if err != nil {
+		return
+	}
+
+	u.conn.Wg.privateKey = privateKey
+	u.conn.Wg.publicKey = publicKey
+
+	return
+}
+
+func (u *WgUsp) Connect(data *ConnData) (err error) {
+	err = u.conn.Wg.Connect(data)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (u *WgUsp) WatchConnection() (err error) {
+	err = u.conn.Wg.WatchConnection()
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (u *WgUsp) GetTransfer() (rx, tx uint64, err error) {
+	_, rx, tx, err = u.getPeer()
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (u *WgUsp) Disconnect() {
+	u.conn.Wg.Disconnect()
+}
+
+func (u *WgUsp) IsNetstack() bool {
+	return u.netstack
+}
+
+func keyHex(key string) (keyHex string, err error) {
+	keyByt, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "wgusp: Failed to decode key"),
+		}
+		return
+	}
+
+	keyHex = hex.EncodeToString(keyByt)
+	return
+}
+
+func parseUspAddr(addr string) (ip netip.Addr, err error) {
+	addr = strings.TrimSpace(addr)
+	if strings.Contains(addr, "/") {
+		prefix, e := netip.ParsePrefix(addr)
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "wgusp: Failed to parse address"),
+			}
+			return
+		}
+		ip = prefix.Addr()
+		return
+	}
+
+	ip, err = netip.ParseAddr(addr)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "wgusp: Failed to parse address"),
+		}
+		return
+	}
+
+	return
+}
+
+func (u *WgUsp) uapiConf(conf *wglink.Config, fwmark uint32) (
+	uapi string, err error) {
+
+	privateKey, err := keyHex(conf.PrivateKey)
+	if err != nil {
+		return
+	}
+
+	publicKey, err := keyHex(conf.PublicKey)
+	if err != nil {
+		return
+	}
+
+	endpoint, err := net.ResolveUDPAddr("udp", conf.Endpoint)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "wgusp: Failed to resolve endpoint"),
+		}
+		return
+	}
+
+	output := &strings.Builder{}
+	output.WriteString(fmt.Sprintf("private_key=%s\n", privateKey))
+	if fwmark != 0 {
+		output.WriteString(fmt.Sprintf("fwmark=%d\n", fwmark))
+	}
+	output.WriteString("replace_peers=true\n")
+	output.WriteString(fmt.Sprintf("public_key=%s\n", publicKey))
+	output.WriteString(fmt.Sprintf("endpoint=%s\n", endpoint.String()))
+	output.WriteString("replace_allowed_ips=true\n")
+	for _, allowedIp := range conf.AllowedIps {
+		output.WriteString(fmt.Sprintf("allowed_ip=%s\n", allowedIp))
+	}
+
+	uapi = output.String()
+	return
+}
+
+func (u *WgUsp) logger() *device.Logger {
+	return &device.Logger{
+		Verbosef: device.DiscardLogf,
+		Errorf: func(format string, args ...interface{}) {
+			logrus.WithFields(u.conn.Fields(logrus.Fields{
+				"error": fmt.Sprintf(format, args...),
+			})).Error("wgusp: Userspace device error")
+		},
+	}
+}
+
+func (u *WgUsp) createNetstack(conf *wglink.Config, mtu int) (
+	tunDev tun.Device, err error) {
+
+	addrs := []netip.Addr{}
+	for _, addr := range conf.Addresses {
+		ip, e := parseUspAddr(addr)
+		if e != nil {
+			err = e
+			return
+		}
+		addrs = append(addrs, ip)
+	}
+
+	dnsAddrs := []netip.Addr{}
+	for _, server := range u.conn.Data.DnsServers {
+		ip, e := netip.ParseAddr(server)
+		if e != nil {
+			continue
+		}
+		dnsAddrs = append(dnsAddrs, ip)
+	}
+
+	tunDev, tnet, err := netstack.CreateNetTUN(addrs, dnsAddrs, mtu)
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "wgusp: Failed to create netstack"),
+		}
+		return
+	}
+
+	transport := clientTransport.Clone()
+	transport.DialContext = tnet.DialContext
+
+	u.tnet = tnet
+	u.netstack = true
+	u.httpClient = &http.Client{
+		Transport: transport,
+		Timeout:   40 * time.Second,
+	}
+
+	return
+}
+
+func (u *WgUsp) conf(conf *wglink.Config) (err error) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	if conf == nil {
+		err = &errortypes.ParseError{
+			errors.New("wgusp: Missing wg configuration"),
+		}
+		return
+	}
+
+	mtu := conf.Mtu
+	if mtu == 0 {
+		mtu = wgUspDefaultMtu
+	}
+
+	var tunDev tun.Device
+	if runtime.GOOS == "linux" && !u.conn.Profile.ProxyMode {
+		tunDev, err = tun.CreateTUN(conf.Iface, mtu)
+		if err != nil {
+			logrus.WithFields(u.conn.Fields(logrus.Fields{
+				"error": err,
+			})).Info("wgusp: Failed to create tun device, using netstack")
+			tunDev = nil
+			err = nil
+		}
+	}
+
+	if tunDev == nil {
+		tunDev, err = u.createNetstack(conf, mtu)
+		if err != nil {
+			return
+		}
+	}
+
+	fwmark := uint32(0)
+	if !u.netstack && !conf.NoDefaultRoute {
+		for _, allowedIp := range conf.AllowedIps {
+			if strings.HasSuffix(allowedIp, "/0") {
+				fwmark, err = wglink.Fwmark(conf.Iface)
+				if err != nil {
+					_ = tunDev.Close()
+					return
+				}
+				break
+			}
+		}
+	}
+
+	uapi, err := u.uapiConf(conf, fwmark)
+	if err != nil {
+		_ = tunDev.Close()
+		return
+	}
+
+	dev := device.NewDevice(tunDev, conn.NewDefaultBind(), u.logger())
+	u.device = dev
+
+	err = dev.IpcSet(uapi)
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "wgusp: Failed to configure device"),
+		}
+		return
+	}
+
+	err = dev.Up()
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "wgusp: Failed to start device"),
+		}
+		return
+	}
+
+	if !u.netstack {
+		err = wglink.Configure(conf)
+		if err != nil {
+			return
+		}
+
+		return
+	}
+
+	prxy := &proxy.Proxy{
+		Address: u.conn.Profile.ProxyAddress,
+		Dial:    u.tnet.DialContext,
+	}
+
+	err = prxy.Start()
+	if err != nil {
+		return
+	}
+
+	u.proxy = prxy
+	u.conn.Data.ProxyAddr = prxy.Address
+
+	return
+}
+
+func (u *WgUsp) getPeer() (handshake int64, rx, tx uint64, err error) {
+	u.lock.Lock()
+	dev := u.device
+	u.lock.Unlock()
+
+	if dev == nil {
+		return
+	}
+
+	publicKey, err := keyHex(u.conn.Wg.serverPubKey)
+	if err != nil {
+		return
+	}
+
+	output, err := dev.IpcGet()
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "wgusp: Failed to get device status"),
+		}
+		return
+	}
+
+	peer := false
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		key, val, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "public_key":
+			peer = val == publicKey
+		case "last_handshake_time_sec":
+			if peer {
+				handshake, _ = strconv.ParseInt(val, 10, 64)
+			}
+		case "rx_bytes":
+			if peer {
+				rx, _ = strconv.ParseUint(val, 10, 64)
+			}
+		case "tx_bytes":
+			if peer {
+				tx, _ = strconv.ParseUint(val, 10, 64)
+			}
+		}
+	}
+
+	return
+}
+
+func (u *WgUsp) clear() {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	if u.proxy != nil {
+		u.proxy.Close()
+		u.proxy = nil
+	}
+
+	if u.device != nil {
+		if !u.netstack {
+			err := wglink.Down(u.conn.Data.Iface)
+			if err != nil {
+				logrus.WithFields(u.conn.Fields(logrus.Fields{
+					"error": err,
+				})).Error("wgusp: Failed to remove tun interface")
+			}
+		}
+
+		u.device.Close()
+		u.device = nil
+	}
+
+	u.tnet = nil
+	u.httpClient = nil
+}
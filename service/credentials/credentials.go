@@ -0,0 +1,97 @@
+// Storage for saved profile passwords outside of the profile conf.
+package credentials
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+type Store interface {
+	Name() string
+	Get(prflId string) (password string, err error)
+	Set(prflId, password string) (err error)
+	Remove(prflId string) (err error)
+}
+
+var (
+	store     Store
+	storeLock = sync.Mutex{}
+	fileStore = &FileStore{}
+)
+
+func getStore() Store {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+
+	if store == nil {
+		store = newKeyring()
+		if store == nil {
+			store = fileStore
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"store": store.Name(),
+		}).Info("credentials: Using credential store")
+	}
+
+	return store
+}
+
+// Get returns the saved password, passwords saved to the file store when
+// the keyring was unavailable are also checked
+func Get(prflId string) (password string, err error) {
+	str := getStore()
+
+	password, err = str.Get(prflId)
+	if err != nil || password != "" || str == fileStore {
+		return
+	}
+
+	password, err = fileStore.Get(prflId)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Set saves the password, an empty password removes the saved password.
+// The file store is only used when no keyring is available.
+func Set(prflId, password string) (err error) {
+	if password == "" {
+		err = Remove(prflId)
+		return
+	}
+
+	str := getStore()
+
+	err = str.Set(prflId, password)
+	if err != nil {
+		return
+	}
+
+	if str != fileStore {
+		_ = fileStore.Remove(prflId)
+	}
+
+	return
+}
+
+func Remove(prflId string) (err error) {
+	str := getStore()
+
+	if str != fileStore {
+		err = str.Remove(prflId)
+		if err != nil {
+			return
+		}
+	}
+
+	err = fileStore.Remove(prflId)
+	if err != nil {
+		return
+	}
+
+	return
+}
@@ -0,0 +1,99 @@
+package credentials
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/platform"
+	"github.com/pritunl/pritunl-client-electron/service/secret"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+// FileStore saves passwords encrypted with the machine key
+type FileStore struct{}
+
+func (f *FileStore) path(prflId string) string {
+	return filepath.Join(GetPath(), prflId+".cred")
+}
+
+func (f *FileStore) Name() string {
+	return "file"
+}
+
+func (f *FileStore) Get(prflId string) (password string, err error) {
+	data, err := ioutil.ReadFile(f.path(prflId))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "credentials: Failed to read credentials"),
+		}
+		return
+	}
+
+	password, err = secret.Decrypt(string(data))
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (f *FileStore) Set(prflId, password string) (err error) {
+	err = platform.MkdirSecure(GetPath())
+	if err != nil {
+		return
+	}
+
+	encPassword, err := secret.Encrypt(password)
+	if err != nil {
+		return
+	}
+
+	err = utils.CreateWrite(f.path(prflId), encPassword, 0600)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (f *FileStore) Remove(prflId string) (err error) {
+	err = os.Remove(f.path(prflId))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "credentials: Failed to remove credentials"),
+		}
+		return
+	}
+
+	return
+}
+
+func GetPath() string {
+	switch runtime.GOOS {
+	case "windows":
+		return filepath.Join(utils.GetWinDrive(), "ProgramData",
+			"Pritunl", "Credentials")
+	case "darwin":
+		return filepath.Join("/", "Library",
+			"Application Support", "Pritunl", "Credentials")
+	case "linux":
+		return filepath.Join("/", "var",
+			"lib", "pritunl-client", "credentials")
+	default:
+		panic("credentials: Not implemented")
+	}
+}
@@ -0,0 +1,9 @@
+// Interface DNS configuration for connected profiles.
+package dns
+
+type Config struct {
+	Iface        string
+	Servers      []string
+	Domains      []string
+	DefaultRoute bool
+}
@@ -0,0 +1,27 @@
+package dns
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+func Set(conf *Config) (err error) {
+	err = &errortypes.ExecError{
+		errors.New("dns: Interface dns not supported"),
+	}
+	return
+}
+
+func SplitSupported() bool {
+	return false
+}
+
+func LinkServers(iface string) (servers []string, err error) {
+	return
+}
+
+func Clear(iface string) {
+}
+
+func Clean() {
+}
@@ -0,0 +1,519 @@
+package dns
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/netip"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/godbus/dbus/v5"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	resolvPath      = "/etc/resolv.conf"
+	resolvBackup    = "/etc/resolv.conf.pritunl"
+	resolvedDest    = "org.freedesktop.resolve1"
+	resolvedPath    = "/org/freedesktop/resolve1"
+	resolvedManager = "org.freedesktop.resolve1.Manager"
+	resolvedLink    = "org.freedesktop.resolve1.Link"
+)
+
+const (
+	modeResolved   = "resolved"
+	modeResolvconf = "resolvconf"
+	modeResolv     = "resolv"
+)
+
+var (
+	lock         = sync.Mutex{}
+	ifaces       = map[string]string{}
+	resolvConfs  = map[string]*Config{}
+	resolvIfaces = []string{}
+)
+
+type resolvedAddr struct {
+	Family  int32
+	Address []byte
+}
+
+type resolvedDomain struct {
+	Domain      string
+	RoutingOnly bool
+}
+
+func resolvedActive() bool {
+	data, err := ioutil.ReadFile(resolvPath)
+	if err != nil {
+		return true
+	}
+
+	dataStr := string(data)
+	if !strings.Contains(dataStr, "systemd-resolved") &&
+		!strings.Contains(dataStr, "127.0.0.53") {
+
+		return false
+	}
+
+	return true
+}
+
+func resolvedCall(method string, args ...interface{}) (err error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "dns: Failed to connect to system bus"),
+		}
+		return
+	}
+	defer conn.Close()
+
+	err = conn.Object(resolvedDest, resolvedPath).Call(
+		resolvedManager+"."+method, 0, args...).Err
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrapf(err, "dns: Failed to call resolved %s", method),
+		}
+		return
+	}
+
+	return
+}
+
+func LinkServers(iface string) (servers []string, err error) {
+	if !resolvedActive() {
+		return
+	}
+
+	ifc, err := net.InterfaceByName(iface)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "dns: Failed to find interface"),
+		}
+		return
+	}
+
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "dns: Failed to connect to system bus"),
+		}
+		return
+	}
+	defer conn.Close()
+
+	var linkPath dbus.ObjectPath
+	err = conn.Object(resolvedDest, resolvedPath).Call(
+		resolvedManager+".GetLink", 0, int32(ifc.Index)).Store(&linkPath)
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "dns: Failed to get resolved link"),
+		}
+		return
+	}
+
+	prop, err := conn.Object(resolvedDest, linkPath).GetProperty(
+		resolvedLink + ".DNS")
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "dns: Failed to get resolved link dns"),
+		}
+		return
+	}
+
+	addrs := []resolvedAddr{}
+	err = prop.Store(&addrs)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "dns: Failed to parse resolved link dns"),
+		}
+		return
+	}
+
+	servers = []string{}
+	for _, addr := range addrs {
+		ip, ok := netip.AddrFromSlice(addr.Address)
+		if !ok {
+			continue
+		}
+		servers = append(servers, ip.String())
+	}
+
+	return
+}
+
+// Resolved sends link queries out of the link interface so loopback
+// servers such as the local forwarder must use the global resolv.conf
+func loopbackServers(servers []string) bool {
+	for _, server := range servers {
+		addr, err := netip.ParseAddr(strings.TrimSpace(server))
+		if err != nil || !addr.IsLoopback() {
+			return false
+		}
+	}
+
+	return true
+}
+
+func setResolved(conf *Config) (err error) {
+	ifc, err := net.InterfaceByName(conf.Iface)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "dns: Failed to find interface"),
+		}
+		return
+	}
+	index := int32(ifc.Index)
+
+	addrs := []resolvedAddr{}
+	for _, server := range conf.Servers {
+		addr, e := netip.ParseAddr(strings.TrimSpace(server))
+		if e != nil {
+			continue
+		}
+
+		family := int32(unix.AF_INET)
+		if !addr.Is4() && !addr.Is4In6() {
+			family = unix.AF_INET6
+		} else {
+			addr = addr.Unmap()
+		}
+
+		addrs = append(addrs, resolvedAddr{
+			Family:  family,
+			Address: addr.AsSlice(),
+		})
+	}
+
+	if len(addrs) == 0 {
+		err = &errortypes.ParseError{
+			errors.New("dns: No valid dns servers"),
+		}
+		return
+	}
+
+	domains := []resolvedDomain{}
+	for _, domain := range conf.Domains {
+		domain = strings.TrimSpace(domain)
+		routing := strings.HasPrefix(domain, "~")
+
+		domain = strings.TrimPrefix(domain, "~")
+		if domain == "" || domain == "." {
+			continue
+		}
+
+		domains = append(domains, resolvedDomain{
+			Domain:      domain,
+			RoutingOnly: routing,
+		})
+	}
+
+	if conf.DefaultRoute {
+		domains = append(domains, resolvedDomain{
+			Domain:      ".",
+			RoutingOnly: true,
+		})
+	}
+
+	err = resolvedCall("SetLinkDNS", index, addrs)
+	if err != nil {
+		return
+	}
+
+	err = resolvedCall("SetLinkDomains", index, domains)
+	if err != nil {
+		return
+	}
+
+	// Not available before systemd 240, the routing domain is used instead
+	e := resolvedCall("SetLinkDefaultRoute", index, conf.DefaultRoute)
+	if e != nil {
+		logrus.WithFields(logrus.Fields{
+			"iface": conf.Iface,
+			"error": e,
+		}).Info("dns: Failed to set resolved default route")
+	}
+
+	_ = resolvedCall("FlushCaches")
+
+	return
+}
+
+func clearResolved(iface string) {
+	ifc, err := net.InterfaceByName(iface)
+	if err != nil {
+		return
+	}
+
+	err = resolvedCall("RevertLink", int32(ifc.Index))
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"iface": iface,
+			"error": err,
+		}).Warn("dns: Failed to revert resolved link")
+	}
+}
+
+func resolvconfIface(iface string) string {
+	exists, _ := utils.Exists("/etc/resolvconf/interface-order")
+	if exists {
+		return "tun." + iface
+	}
+	return iface
+}
+
+func resolvManaged() bool {
+	info, err := os.Lstat(resolvPath)
+	if err != nil || !info.Mode().IsRegular() {
+		return true
+	}
+
+	exists, _ := utils.ExistsFile(resolvBackup)
+	if exists {
+		return false
+	}
+
+	data, err := ioutil.ReadFile(resolvPath)
+	if err != nil {
+		return true
+	}
+
+	dataStr := string(data)
+	if strings.Contains(dataStr, "Generated by") ||
+		strings.Contains(dataStr, "DO NOT EDIT") {
+
+		return true
+	}
+
+	return false
+}
+
+func writeResolv() (err error) {
+	if len(resolvConfs) == 0 {
+		restoreResolv()
+		return
+	}
+
+	servers := []string{}
+	domains := []string{}
+	serversSet := map[string]bool{}
+	domainsSet := map[string]bool{}
+	for _, iface := range resolvIfaces {
+		conf := resolvConfs[iface]
+		if conf == nil {
+			continue
+		}
+
+		for _, server := range conf.Servers {
+			if !serversSet[server] {
+				serversSet[server] = true
+				servers = append(servers, server)
+			}
+		}
+		for _, domain := range conf.Domains {
+			if !domainsSet[domain] {
+				domainsSet[domain] = true
+				domains = append(domains, domain)
+			}
+		}
+	}
+
+	data := &strings.Builder{}
+	for _, server := range servers {
+		data.WriteString(fmt.Sprintf("nameserver %s\n", server))
+	}
+	if len(domains) > 0 {
+		data.WriteString(fmt.Sprintf(
+			"search %s\n", strings.Join(domains, " ")))
+	}
+
+	exists, err := utils.ExistsFile(resolvBackup)
+	if err != nil {
+		return
+	}
+
+	if !exists {
+		err = utils.Copy(resolvPath, resolvBackup)
+		if err != nil {
+			return
+		}
+	}
+
+	err = utils.CreateWrite(resolvPath, data.String(), 0644)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func setResolv(conf *Config) (mode string, err error) {
+	resolvconfPath, e := exec.LookPath("resolvconf")
+	if e == nil {
+		data := &strings.Builder{}
+		for _, server := range conf.Servers {
+			data.WriteString(fmt.Sprintf("nameserver %s\n", server))
+		}
+		if len(conf.Domains) > 0 {
+			data.WriteString(fmt.Sprintf(
+				"search %s\n", strings.Join(conf.Domains, " ")))
+		}
+
+		_, err = utils.ExecInputOutputCombindLogged(
+			data.String(),
+			resolvconfPath,
+			"-a", resolvconfIface(conf.Iface), "-m", "0", "-x",
+		)
+		if err != nil {
+			return
+		}
+
+		mode = modeResolvconf
+		return
+	}
+
+	if resolvManaged() {
+		logrus.WithFields(logrus.Fields{
+			"iface": conf.Iface,
+		}).Warn("dns: Resolv conf is managed by the system, " +
+			"skipping dns configuration")
+		return
+	}
+
+	resolvConfs[conf.Iface] = conf
+	resolvIfaces = append(resolvIfaces, conf.Iface)
+
+	err = writeResolv()
+	if err != nil {
+		clearResolv(conf.Iface)
+		return
+	}
+
+	mode = modeResolv
+	return
+}
+
+func clearResolv(iface string) {
+	delete(resolvConfs, iface)
+
+	resolvIfacesNew := []string{}
+	for _, resolvIface := range resolvIfaces {
+		if resolvIface != iface {
+			resolvIfacesNew = append(resolvIfacesNew, resolvIface)
+		}
+	}
+	resolvIfaces = resolvIfacesNew
+}
+
+func restoreResolv() {
+	exists, _ := utils.ExistsFile(resolvBackup)
+	if !exists {
+		return
+	}
+
+	err := utils.Copy(resolvBackup, resolvPath)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("dns: Failed to restore resolv conf")
+		return
+	}
+
+	_ = os.Remove(resolvBackup)
+}
+
+func Set(conf *Config) (err error) {
+	if conf == nil || conf.Iface == "" || len(conf.Servers) == 0 {
+		return
+	}
+
+	Clear(conf.Iface)
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if resolvedActive() && !loopbackServers(conf.Servers) {
+		err = setResolved(conf)
+		if err == nil {
+			ifaces[conf.Iface] = modeResolved
+			return
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"iface": conf.Iface,
+			"error": err,
+		}).Warn("dns: Failed to configure systemd-resolved, " +
+			"falling back to resolv.conf")
+
+		clearResolved(conf.Iface)
+		err = nil
+	}
+
+	mode, err := setResolv(conf)
+	if err != nil {
+		return
+	}
+	if mode != "" {
+		ifaces[conf.Iface] = mode
+	}
+
+	return
+}
+
+// SplitSupported returns true when systemd-resolved provides per link
+// routing domains
+func SplitSupported() bool {
+	return resolvedActive()
+}
+
+func Clear(iface string) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	mode, ok := ifaces[iface]
+	if !ok {
+		return
+	}
+	delete(ifaces, iface)
+
+	switch mode {
+	case modeResolved:
+		clearResolved(iface)
+		break
+	case modeResolvconf:
+		resolvconfPath, e := exec.LookPath("resolvconf")
+		if e != nil {
+			return
+		}
+
+		_, _ = utils.ExecCombinedOutputLogged(
+			nil,
+			resolvconfPath,
+			"-d", resolvconfIface(iface), "-f",
+		)
+		break
+	case modeResolv:
+		clearResolv(iface)
+
+		err := writeResolv()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"iface": iface,
+				"error": err,
+			}).Error("dns: Failed to update resolv conf")
+		}
+		break
+	}
+}
+
+func Clean() {
+	restoreResolv()
+}
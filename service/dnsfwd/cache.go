@@ -0,0 +1,168 @@
+package dnsfwd
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	cacheMaxTtl     = 300
+	cacheMaxEntries = 4096
+)
+
+type cacheEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+type cache struct {
+	lock    sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+func newCache() *cache {
+	return &cache{
+		entries: map[string]*cacheEntry{},
+	}
+}
+
+func cacheKey(question dnsmessage.Question, dnssecOk, checkingDisabled,
+	recursionDesired bool) string {
+
+	return fmt.Sprintf("%s|%d|%d|%t|%t|%t",
+		strings.ToLower(question.Name.String()),
+		question.Type, question.Class,
+		dnssecOk, checkingDisabled, recursionDesired,
+	)
+}
+
+// responseTtl returns the cache lifetime of a response, truncated and
+// failed responses are not cached
+func responseTtl(data []byte) (ttl uint32, ok bool) {
+	parser := dnsmessage.Parser{}
+
+	header, err := parser.Start(data)
+	if err != nil || header.Truncated {
+		return
+	}
+
+	if header.RCode != dnsmessage.RCodeSuccess &&
+		header.RCode != dnsmessage.RCodeNameError {
+
+		return
+	}
+
+	err = parser.SkipAllQuestions()
+	if err != nil {
+		return
+	}
+
+	ttl = cacheMaxTtl
+	found := false
+
+	for {
+		rh, e := parser.AnswerHeader()
+		if e != nil {
+			break
+		}
+
+		found = true
+		if rh.TTL < ttl {
+			ttl = rh.TTL
+		}
+
+		e = parser.SkipAnswer()
+		if e != nil {
+			return
+		}
+	}
+
+	for {
+		rh, e := parser.AuthorityHeader()
+		if e != nil {
+			break
+		}
+
+		if rh.Type == dnsmessage.TypeSOA {
+			found = true
+			if rh.TTL < ttl {
+				ttl = rh.TTL
+			}
+		}
+
+		e = parser.SkipAuthority()
+		if e != nil {
+			return
+		}
+	}
+
+	if !found || ttl == 0 {
+		return
+	}
+
+	ok = true
+	return
+}
+
+func (c *cache) Get(key string, id uint16) (data []byte) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	entry := c.entries[key]
+	if entry == nil {
+		return
+	}
+
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return
+	}
+
+	data = make([]byte, len(entry.data))
+	copy(data, entry.data)
+	binary.BigEndian.PutUint16(data, id)
+
+	return
+}
+
+func (c *cache) Put(key string, data []byte) {
+	ttl, ok := responseTtl(data)
+	if !ok {
+		return
+	}
+
+	entry := &cacheEntry{
+		data:    make([]byte, len(data)),
+		expires: time.Now().Add(time.Duration(ttl) * time.Second),
+	}
+	copy(entry.data, data)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if len(c.entries) >= cacheMaxEntries {
+		now := time.Now()
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+
+		if len(c.entries) >= cacheMaxEntries {
+			c.entries = map[string]*cacheEntry{}
+		}
+	}
+
+	c.entries[key] = entry
+}
+
+func (c *cache) Flush() {
+	c.lock.Lock()
+	c.entries = map[string]*cacheEntry{}
+	c.lock.Unlock()
+}
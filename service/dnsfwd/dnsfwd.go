@@ -0,0 +1,445 @@
+// Local DNS forwarder sending tunnel domains to the tunnel resolvers and
+// all other queries to the original system resolvers.
+package dnsfwd
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultAddress = "127.0.0.1:53"
+	maxPacket      = 65535
+	tcpTimeout     = 10 * time.Second
+)
+
+var (
+	fwd     *Forwarder
+	fwdLock = sync.Mutex{}
+)
+
+type route struct {
+	id           string
+	domains      []string
+	servers      []string
+	defaultRoute bool
+}
+
+type Forwarder struct {
+	Address  string
+	lock     sync.RWMutex
+	udpConn  net.PacketConn
+	listener net.Listener
+	routes   map[string]*route
+	system   []string
+	cache    *cache
+	closed   bool
+}
+
+func (f *Forwarder) Start() (err error) {
+	if f.Address == "" {
+		f.Address = DefaultAddress
+	}
+
+	udpConn, err := net.ListenPacket("udp", f.Address)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "dnsfwd: Failed to listen udp"),
+		}
+		return
+	}
+
+	listener, err := net.Listen("tcp", f.Address)
+	if err != nil {
+		_ = udpConn.Close()
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "dnsfwd: Failed to listen tcp"),
+		}
+		return
+	}
+
+	f.lock.Lock()
+	f.udpConn = udpConn
+	f.listener = listener
+	f.routes = map[string]*route{}
+	f.cache = newCache()
+	f.lock.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"address": f.Address,
+	}).Info("dnsfwd: DNS forwarder started")
+
+	go f.serveUdp(udpConn)
+	go f.serveTcp(listener)
+
+	return
+}
+
+func (f *Forwarder) Close() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.closed {
+		return
+	}
+	f.closed = true
+
+	if f.udpConn != nil {
+		_ = f.udpConn.Close()
+	}
+	if f.listener != nil {
+		_ = f.listener.Close()
+	}
+}
+
+func (f *Forwarder) isClosed() bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.closed
+}
+
+// Servers returns the addresses the system resolver should be pointed at
+func (f *Forwarder) Servers() []string {
+	host, _, err := net.SplitHostPort(f.Address)
+	if err != nil {
+		host = f.Address
+	}
+
+	return []string{host}
+}
+
+func (f *Forwarder) Register(id string, domains, servers []string,
+	defaultRoute bool) {
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if len(f.routes) == 0 {
+		f.system = f.filterServers(SystemServers())
+	}
+
+	rte := &route{
+		id:           id,
+		servers:      []string{},
+		defaultRoute: defaultRoute,
+	}
+
+	for _, domain := range domains {
+		domain = normalizeName(domain)
+		if domain == "" || domain == "." {
+			continue
+		}
+		rte.domains = append(rte.domains, domain)
+	}
+
+	for _, server := range servers {
+		rte.servers = append(rte.servers, serverAddress(server))
+	}
+
+	f.routes[id] = rte
+	f.cache.Flush()
+}
+
+func (f *Forwarder) Unregister(id string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if _, ok := f.routes[id]; !ok {
+		return
+	}
+
+	delete(f.routes, id)
+	f.cache.Flush()
+}
+
+func (f *Forwarder) filterServers(servers []string) (filtered []string) {
+	filtered = []string{}
+	local := serverAddress(f.Address)
+
+	for _, server := range servers {
+		server = serverAddress(server)
+		if server == local {
+			continue
+		}
+		filtered = append(filtered, server)
+	}
+
+	return
+}
+
+// Match returns the upstream servers for a query name, the longest
+// matching tunnel domain is used before any default tunnel route
+func (f *Forwarder) Match(name string) (servers []string) {
+	name = normalizeName(name)
+
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	ids := []string{}
+	for id := range f.routes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	matchLen := -1
+	for _, id := range ids {
+		rte := f.routes[id]
+
+		for _, domain := range rte.domains {
+			if name != domain && !strings.HasSuffix(name, "."+domain) {
+				continue
+			}
+
+			if len(domain) > matchLen && len(rte.servers) > 0 {
+				matchLen = len(domain)
+				servers = rte.servers
+			}
+		}
+	}
+
+	if servers != nil {
+		return
+	}
+
+	for _, id := range ids {
+		rte := f.routes[id]
+		if rte.defaultRoute && len(rte.servers) > 0 {
+			servers = rte.servers
+			return
+		}
+	}
+
+	servers = f.system
+	return
+}
+
+func (f *Forwarder) serveUdp(udpConn net.PacketConn) {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"trace": string(debug.Stack()),
+				"panic": panc,
+			}).Error("dnsfwd: Serve udp panic")
+		}
+	}()
+
+	buf := make([]byte, maxPacket)
+	for {
+		n, addr, err := udpConn.ReadFrom(buf)
+		if err != nil {
+			if f.isClosed() {
+				return
+			}
+
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("dnsfwd: Failed to read udp query")
+
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
+		query := make([]byte, n)
+		copy(query, buf[:n])
+
+		go func() {
+			resp := f.handle(query, "udp")
+			if resp != nil {
+				_, _ = udpConn.WriteTo(resp, addr)
+			}
+		}()
+	}
+}
+
+func (f *Forwarder) serveTcp(listener net.Listener) {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"trace": string(debug.Stack()),
+				"panic": panc,
+			}).Error("dnsfwd: Serve tcp panic")
+		}
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if f.isClosed() {
+				return
+			}
+
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("dnsfwd: Failed to accept tcp connection")
+
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
+		go f.handleTcp(conn)
+	}
+}
+
+func (f *Forwarder) handleTcp(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		_ = conn.SetDeadline(time.Now().Add(tcpTimeout))
+
+		query, err := readTcpMsg(conn)
+		if err != nil {
+			return
+		}
+
+		resp := f.handle(query, "tcp")
+		if resp == nil {
+			return
+		}
+
+		err = writeTcpMsg(conn, resp)
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (f *Forwarder) handle(query []byte, network string) (resp []byte) {
+	resp, err := f.Resolve(query, network)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Warn("dnsfwd: Failed to resolve query")
+
+		resp = failure(query)
+	}
+
+	return
+}
+
+func readTcpMsg(conn io.Reader) (msg []byte, err error) {
+	length := make([]byte, 2)
+	_, err = io.ReadFull(conn, length)
+	if err != nil {
+		return
+	}
+
+	msg = make([]byte, int(binary.BigEndian.Uint16(length)))
+	_, err = io.ReadFull(conn, msg)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func writeTcpMsg(conn io.Writer, msg []byte) (err error) {
+	data := make([]byte, 2+len(msg))
+	binary.BigEndian.PutUint16(data, uint16(len(msg)))
+	copy(data[2:], msg)
+
+	_, err = conn.Write(data)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func normalizeName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.TrimPrefix(name, "~")
+	if name != "." {
+		name = strings.TrimSuffix(name, ".")
+	}
+	return name
+}
+
+func serverAddress(server string) string {
+	server = strings.TrimSpace(server)
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+	return net.JoinHostPort(strings.Trim(server, "[]"), "53")
+}
+
+func Active() bool {
+	fwdLock.Lock()
+	defer fwdLock.Unlock()
+	return fwd != nil
+}
+
+func Start(address string) (err error) {
+	fwdLock.Lock()
+	defer fwdLock.Unlock()
+
+	if fwd != nil {
+		return
+	}
+
+	forwarder := &Forwarder{
+		Address: address,
+	}
+
+	err = forwarder.Start()
+	if err != nil {
+		return
+	}
+
+	fwd = forwarder
+	return
+}
+
+func Stop() {
+	fwdLock.Lock()
+	defer fwdLock.Unlock()
+
+	if fwd == nil {
+		return
+	}
+
+	fwd.Close()
+	fwd = nil
+}
+
+// Register adds the tunnel resolvers of a connection and returns the
+// servers the connection should configure, nil if the forwarder is inactive
+func Register(id string, domains, servers []string,
+	defaultRoute bool) (fwdServers []string) {
+
+	fwdLock.Lock()
+	forwarder := fwd
+	fwdLock.Unlock()
+
+	if forwarder == nil {
+		return
+	}
+
+	forwarder.Register(id, domains, servers, defaultRoute)
+	fwdServers = forwarder.Servers()
+
+	return
+}
+
+func Unregister(id string) {
+	fwdLock.Lock()
+	forwarder := fwd
+	fwdLock.Unlock()
+
+	if forwarder == nil {
+		return
+	}
+
+	forwarder.Unregister(id)
+}
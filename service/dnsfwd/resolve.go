@@ -0,0 +1,234 @@
+package dnsfwd
+
+import (
+	"net"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	queryTimeout = 5 * time.Second
+)
+
+type query struct {
+	id       uint16
+	key      string
+	name     string
+	question dnsmessage.Question
+}
+
+func parseQuery(data []byte) (qry *query, err error) {
+	parser := dnsmessage.Parser{}
+
+	header, err := parser.Start(data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "dnsfwd: Failed to parse query header"),
+		}
+		return
+	}
+
+	if header.Response {
+		err = &errortypes.ParseError{
+			errors.New("dnsfwd: Received response as query"),
+		}
+		return
+	}
+
+	question, err := parser.Question()
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "dnsfwd: Failed to parse query question"),
+		}
+		return
+	}
+
+	// DNSSEC OK is carried in the OPT record, queries with and without it
+	// receive different answers and are cached separately
+	dnssecOk := false
+	err = parser.SkipAllQuestions()
+	if err == nil {
+		err = parser.SkipAllAnswers()
+	}
+	if err == nil {
+		err = parser.SkipAllAuthorities()
+	}
+	if err == nil {
+		for {
+			rh, e := parser.AdditionalHeader()
+			if e != nil {
+				break
+			}
+
+			if rh.Type == dnsmessage.TypeOPT {
+				dnssecOk = rh.DNSSECAllowed()
+			}
+
+			e = parser.SkipAdditional()
+			if e != nil {
+				break
+			}
+		}
+	}
+	err = nil
+
+	qry = &query{
+		id:       header.ID,
+		name:     question.Name.String(),
+		question: question,
+		key: cacheKey(question, dnssecOk, header.CheckingDisabled,
+			header.RecursionDesired),
+	}
+
+	return
+}
+
+// Resolve forwards a raw query unmodified so EDNS and DNSSEC records are
+// passed through to the client
+func (f *Forwarder) Resolve(data []byte, network string) (
+	resp []byte, err error) {
+
+	qry, err := parseQuery(data)
+	if err != nil {
+		return
+	}
+
+	f.lock.RLock()
+	cch := f.cache
+	f.lock.RUnlock()
+
+	resp = cch.Get(qry.key, qry.id)
+	if resp != nil {
+		return
+	}
+
+	servers := f.Match(qry.name)
+	if len(servers) == 0 {
+		err = &errortypes.RequestError{
+			errors.New("dnsfwd: No upstream dns servers"),
+		}
+		return
+	}
+
+	for _, server := range servers {
+		resp, err = exchange(network, server, data, qry.id)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return
+	}
+
+	cch.Put(qry.key, resp)
+
+	return
+}
+
+func exchange(network, server string, data []byte, id uint16) (
+	resp []byte, err error) {
+
+	conn, err := net.DialTimeout(network, server, queryTimeout)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "dnsfwd: Failed to connect to upstream"),
+		}
+		return
+	}
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(queryTimeout))
+
+	if network == "tcp" {
+		err = writeTcpMsg(conn, data)
+		if err == nil {
+			resp, err = readTcpMsg(conn)
+		}
+		if err != nil {
+			err = &errortypes.RequestError{
+				errors.Wrap(err, "dnsfwd: Failed to exchange tcp query"),
+			}
+			return
+		}
+	} else {
+		_, err = conn.Write(data)
+		if err != nil {
+			err = &errortypes.RequestError{
+				errors.Wrap(err, "dnsfwd: Failed to send udp query"),
+			}
+			return
+		}
+
+		buf := make([]byte, maxPacket)
+		for {
+			n, e := conn.Read(buf)
+			if e != nil {
+				err = &errortypes.RequestError{
+					errors.Wrap(e, "dnsfwd: Failed to read udp response"),
+				}
+				return
+			}
+
+			// Ignore stray responses for other queries
+			if n >= 2 && uint16(buf[0])<<8|uint16(buf[1]) == id {
+				resp = make([]byte, n)
+				copy(resp, buf[:n])
+				break
+			}
+		}
+	}
+
+	if len(resp) < 12 || uint16(resp[0])<<8|uint16(resp[1]) != id {
+		resp = nil
+		err = &errortypes.ParseError{
+			errors.New("dnsfwd: Invalid upstream response"),
+		}
+		return
+	}
+
+	return
+}
+
+func failure(data []byte) (resp []byte) {
+	parser := dnsmessage.Parser{}
+
+	header, err := parser.Start(data)
+	if err != nil {
+		return
+	}
+
+	question, err := parser.Question()
+	if err != nil {
+		return
+	}
+
+	builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{
+		ID:                 header.ID,
+		Response:           true,
+		OpCode:             header.OpCode,
+		RecursionDesired:   header.RecursionDesired,
+		RecursionAvailable: true,
+		RCode:              dnsmessage.RCodeServerFailure,
+	})
+
+	err = builder.StartQuestions()
+	if err != nil {
+		return
+	}
+
+	err = builder.Question(question)
+	if err != nil {
+		return
+	}
+
+	resp, err = builder.Finish()
+	if err != nil {
+		resp = nil
+		return
+	}
+
+	return
+}
@@ -0,0 +1,58 @@
+package dnsfwd
+
+import (
+	"io/ioutil"
+	"strings"
+
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+func SystemServers() (servers []string) {
+	servers = []string{}
+
+	global, err := utils.GetScutilKey("State", "/Network/Global/DNS")
+	if err == nil {
+		inAddrs := false
+		for _, line := range strings.Split(global, "\n") {
+			line = strings.TrimSpace(line)
+
+			if strings.HasPrefix(line, "ServerAddresses") {
+				inAddrs = true
+				continue
+			}
+
+			if !inAddrs {
+				continue
+			}
+
+			if strings.HasPrefix(line, "}") {
+				break
+			}
+
+			lineSpl := strings.SplitN(line, ":", 2)
+			if len(lineSpl) > 1 {
+				servers = append(servers, strings.TrimSpace(lineSpl[1]))
+			}
+		}
+	}
+
+	if len(servers) > 0 {
+		return
+	}
+
+	data, err := ioutil.ReadFile("/etc/resolv.conf")
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "nameserver" {
+			continue
+		}
+
+		servers = append(servers, fields[1])
+	}
+
+	return
+}
@@ -0,0 +1,46 @@
+package dnsfwd
+
+import (
+	"io/ioutil"
+	"strings"
+)
+
+// Upstream servers of systemd-resolved are preferred to avoid forwarding
+// back through the resolved stub
+var resolvPaths = []string{
+	"/run/systemd/resolve/resolv.conf",
+	"/etc/resolv.conf",
+}
+
+func SystemServers() (servers []string) {
+	servers = []string{}
+
+	for _, pth := range resolvPaths {
+		data, err := ioutil.ReadFile(pth)
+		if err != nil {
+			continue
+		}
+
+		servers = parseResolv(string(data))
+		if len(servers) > 0 {
+			return
+		}
+	}
+
+	return
+}
+
+func parseResolv(data string) (servers []string) {
+	servers = []string{}
+
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "nameserver" {
+			continue
+		}
+
+		servers = append(servers, fields[1])
+	}
+
+	return
+}
@@ -0,0 +1,54 @@
+package dnsfwd
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+func SystemServers() (servers []string) {
+	servers = []string{}
+
+	size := uint32(15000)
+	var buf []byte
+	var err error
+
+	for i := 0; i < 3; i++ {
+		buf = make([]byte, size)
+		err = windows.GetAdaptersAddresses(
+			windows.AF_UNSPEC,
+			windows.GAA_FLAG_SKIP_UNICAST|windows.GAA_FLAG_SKIP_ANYCAST|
+				windows.GAA_FLAG_SKIP_MULTICAST,
+			0,
+			(*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0])),
+			&size,
+		)
+		if err != windows.ERROR_BUFFER_OVERFLOW {
+			break
+		}
+	}
+	if err != nil {
+		return
+	}
+
+	adapter := (*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0]))
+	for ; adapter != nil; adapter = adapter.Next {
+		if adapter.OperStatus != windows.IfOperStatusUp ||
+			adapter.IfType == windows.IF_TYPE_SOFTWARE_LOOPBACK {
+
+			continue
+		}
+
+		server := adapter.FirstDnsServerAddress
+		for ; server != nil; server = server.Next {
+			ip := server.Address.IP()
+			if ip == nil {
+				continue
+			}
+
+			servers = append(servers, ip.String())
+		}
+	}
+
+	return
+}
@@ -65,6 +65,8 @@ func Register(engine *gin.Engine) {
 	engine.POST("/network/reset_all", networkAllReset)
 	engine.GET("/profile", profilesGet)
 	engine.GET("/profile/:profile_id", profileGet)
+	engine.GET("/profile/:profile_id/stats", profileStatsGet)
+	engine.POST("/profile/:profile_id/diagnose", profileDiagnosePost)
 	engine.POST("/profile", profilePost)
 	engine.DELETE("/profile", profileDel)
 	engine.DELETE("/profile/:profile_id", profileDel2)
@@ -77,6 +79,8 @@ func Register(engine *gin.Engine) {
 	engine.GET("/sprofile/:profile_id/log", sprofileLogGet)
 	// TODO classic client
 	engine.DELETE("/sprofile/:profile_id/log", sprofileLogDel)
+	engine.GET("/sprofile/:profile_id/history", sprofileHistoryGet)
+	engine.DELETE("/sprofile/:profile_id/credentials", sprofileCredentialsDel)
 	engine.GET("/log/:log_id", logGet)
 	engine.DELETE("/log/:log_id", logDel)
 	engine.PUT("/token", tokenPut)
@@ -87,6 +91,7 @@ func Register(engine *gin.Engine) {
 	engine.POST("/stop", stopPost)
 	engine.POST("/restart", restartPost)
 	engine.GET("/status", statusGet)
+	engine.GET("/routes", routesGet)
 	engine.GET("/state", stateGet)
 	engine.POST("/wakeup", wakeupPost)
 }
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/connection"
+	"github.com/pritunl/pritunl-client-electron/service/metrics"
+)
+
+func MetricsAuth(c *gin.Context) {
+	token := config.Config.MetricsToken
+	if token == "" {
+		c.Next()
+		return
+	}
+
+	authHeader := c.Request.Header.Get("Authorization")
+	if !strings.HasPrefix(authHeader, "Bearer ") ||
+		subtle.ConstantTimeCompare(
+			[]byte(strings.TrimPrefix(authHeader, "Bearer ")),
+			[]byte(token),
+		) != 1 {
+
+		c.AbortWithStatus(401)
+		return
+	}
+	c.Next()
+}
+
+func RegisterMetrics(engine *gin.Engine) {
+	engine.Use(MetricsAuth)
+	engine.Use(Recovery)
+	engine.Use(Errors)
+
+	engine.GET("/metrics", metricsGet)
+}
+
+func metricsGet(c *gin.Context) {
+	states := []*metrics.ProfileState{}
+
+	conns := connection.GlobalStore.GetAll()
+	for _, conn := range conns {
+		states = append(states, &metrics.ProfileState{
+			Id:        conn.Id,
+			Mode:      conn.Data.Mode,
+			Status:    conn.Data.Status,
+			Timestamp: conn.Data.Timestamp,
+		})
+	}
+
+	buf := &bytes.Buffer{}
+	metrics.Write(buf, states)
+
+	c.Data(200, "text/plain; version=0.0.4; charset=utf-8", buf.Bytes())
+}
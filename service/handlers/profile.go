@@ -7,6 +7,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/pritunl-client-electron/service/connection"
 	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/proxy"
 	"github.com/pritunl/pritunl-client-electron/service/sprofile"
 	"github.com/pritunl/pritunl-client-electron/service/utils"
 	"github.com/sirupsen/logrus"
@@ -31,6 +32,14 @@ type profileData struct {
 	DisableGateway     bool     `json:"disable_gateway"`
 	DisableDns         bool     `json:"disable_dns"`
 	RestrictClient     bool     `json:"restrict_client"`
+	ProxyMode          bool     `json:"proxy_mode"`
+	WgUserspace        bool     `json:"wg_userspace"`
+	ProxyAddress       string   `json:"proxy_address"`
+	RouteIncludes      []string `json:"route_includes"`
+	RouteExcludes      []string `json:"route_excludes"`
+	SplitTunnelMode    string   `json:"split_tunnel_mode"`
+	SplitTunnelCgroups []string `json:"split_tunnel_cgroups"`
+	KillSwitch         bool     `json:"kill_switch"`
 	ForceDns           bool     `json:"force_dns"`
 	SsoAuth            bool     `json:"sso_auth"`
 	ServerPublicKey    string   `json:"server_public_key"`
@@ -63,6 +72,44 @@ func profileGet(c *gin.Context) {
 	c.JSON(200, prfl)
 }
 
+func profileStatsGet(c *gin.Context) {
+	prflId := utils.FilterStr(c.Param("profile_id"))
+	if prflId == "" {
+		err := &errortypes.ParseError{
+			errors.New("handler: Invalid profile ID"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	stats := connection.GlobalStore.GetStats(prflId)
+	if stats == nil {
+		utils.AbortWithStatus(c, 404)
+		return
+	}
+
+	c.JSON(200, stats)
+}
+
+func profileDiagnosePost(c *gin.Context) {
+	prflId := utils.FilterStr(c.Param("profile_id"))
+	if prflId == "" {
+		err := &errortypes.ParseError{
+			errors.New("handler: Invalid profile ID"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	conn := connection.GlobalStore.Get(prflId)
+	if conn == nil {
+		utils.AbortWithStatus(c, 404)
+		return
+	}
+
+	c.JSON(200, conn.Diagnose())
+}
+
 func profilePost(c *gin.Context) {
 	data := &profileData{}
 
@@ -84,8 +131,16 @@ func profilePost(c *gin.Context) {
 		return
 	}
 
+	err = proxy.ValidateAddress(data.ProxyAddress)
+	if err != nil {
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
 	sprfl := sprofile.Get(data.Id)
 	if sprfl != nil {
+		connection.ClearExhausted(data.Id)
+
 		err = sprofile.Activate(data.Id, data.Mode, data.Password)
 		if err != nil {
 			utils.AbortWithError(c, 500, err)
@@ -120,6 +175,14 @@ func profilePost(c *gin.Context) {
 		DisableGateway:     data.DisableGateway,
 		DisableDns:         data.DisableDns,
 		RestrictClient:     data.RestrictClient,
+		ProxyMode:          data.ProxyMode,
+		WgUserspace:        data.WgUserspace,
+		ProxyAddress:       data.ProxyAddress,
+		RouteIncludes:      data.RouteIncludes,
+		RouteExcludes:      data.RouteExcludes,
+		SplitTunnelMode:    data.SplitTunnelMode,
+		SplitTunnelCgroups: data.SplitTunnelCgroups,
+		KillSwitch:         data.KillSwitch,
 		ForceDns:           data.ForceDns,
 		SsoAuth:            data.SsoAuth,
 		ServerPublicKey:    data.ServerPublicKey,
@@ -0,0 +1,10 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/connection"
+)
+
+func routesGet(c *gin.Context) {
+	c.JSON(200, connection.GlobalStore.GetRoutes())
+}
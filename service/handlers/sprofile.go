@@ -5,45 +5,56 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/pritunl-client-electron/service/connection"
 	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/log"
+	"github.com/pritunl/pritunl-client-electron/service/proxy"
 	"github.com/pritunl/pritunl-client-electron/service/sprofile"
 	"github.com/pritunl/pritunl-client-electron/service/utils"
 )
 
 type sprofileData struct {
-	Id                 string   `json:"id"`
-	Name               string   `json:"name"`
-	State              bool     `json:"state"`
-	Wg                 bool     `json:"wg"`
-	LastMode           string   `json:"last_mode"`
-	OrganizationId     string   `json:"organization_id"`
-	Organization       string   `json:"organization"`
-	ServerId           string   `json:"server_id"`
-	Server             string   `json:"server"`
-	UserId             string   `json:"user_id"`
-	User               string   `json:"user"`
-	PreConnectMsg      string   `json:"pre_connect_msg"`
-	DynamicFirewall    bool     `json:"dynamic_firewall"`
-	GeoSort            string   `json:"geo_sort"`
-	ForceConnect       bool     `json:"force_connect"`
-	DeviceAuth         bool     `json:"device_auth"`
-	DisableGateway     bool     `json:"disable_gateway"`
-	DisableDns         bool     `json:"disable_dns"`
-	RestrictClient     bool     `json:"restrict_client"`
-	ForceDns           bool     `json:"force_dns"`
-	SsoAuth            bool     `json:"sso_auth"`
-	PasswordMode       string   `json:"password_mode"`
-	Token              bool     `json:"token"`
-	TokenTtl           int      `json:"token_ttl"`
-	Disabled           bool     `json:"disabled"`
-	SyncTime           int64    `json:"sync_time"`
-	SyncHosts          []string `json:"sync_hosts"`
-	SyncHash           string   `json:"sync_hash"`
-	SyncSecret         string   `json:"sync_secret"`
-	SyncToken          string   `json:"sync_token"`
-	ServerPublicKey    []string `json:"server_public_key"`
-	ServerBoxPublicKey string   `json:"server_box_public_key"`
-	RegistrationKey    string   `json:"registration_key"`
-	OvpnData           string   `json:"ovpn_data"`
+	Id                 string         `json:"id"`
+	Name               string         `json:"name"`
+	State              bool           `json:"state"`
+	Wg                 bool           `json:"wg"`
+	LastMode           string         `json:"last_mode"`
+	OrganizationId     string         `json:"organization_id"`
+	Organization       string         `json:"organization"`
+	ServerId           string         `json:"server_id"`
+	Server             string         `json:"server"`
+	UserId             string         `json:"user_id"`
+	User               string         `json:"user"`
+	PreConnectMsg      string         `json:"pre_connect_msg"`
+	DynamicFirewall    bool           `json:"dynamic_firewall"`
+	GeoSort            string         `json:"geo_sort"`
+	ForceConnect       bool           `json:"force_connect"`
+	DeviceAuth         bool           `json:"device_auth"`
+	DisableGateway     bool           `json:"disable_gateway"`
+	DisableDns         bool           `json:"disable_dns"`
+	RestrictClient     bool           `json:"restrict_client"`
+	ProxyMode          bool           `json:"proxy_mode"`
+	WgUserspace        bool           `json:"wg_userspace"`
+	ProxyAddress       string         `json:"proxy_address"`
+	RouteIncludes      []string       `json:"route_includes"`
+	RouteExcludes      []string       `json:"route_excludes"`
+	SplitTunnelMode    string         `json:"split_tunnel_mode"`
+	SplitTunnelCgroups []string       `json:"split_tunnel_cgroups"`
+	KillSwitch         bool           `json:"kill_switch"`
+	ForceDns           bool           `json:"force_dns"`
+	SsoAuth            bool           `json:"sso_auth"`
+	PasswordMode       string         `json:"password_mode"`
+	Token              bool           `json:"token"`
+	TokenTtl           int            `json:"token_ttl"`
+	Disabled           bool           `json:"disabled"`
+	SyncTime           int64          `json:"sync_time"`
+	SyncHosts          []string       `json:"sync_hosts"`
+	SyncHash           string         `json:"sync_hash"`
+	SyncSecret         string         `json:"sync_secret"`
+	SyncToken          string         `json:"sync_token"`
+	ServerPublicKey    []string       `json:"server_public_key"`
+	ServerBoxPublicKey string         `json:"server_box_public_key"`
+	RegistrationKey    string         `json:"registration_key"`
+	NetworkRules       sprofile.Rules `json:"network_rules"`
+	OvpnData           string         `json:"ovpn_data"`
 }
 
 func sprofilesGet(c *gin.Context) {
@@ -102,6 +113,30 @@ func sprofilePut(c *gin.Context) {
 		return
 	}
 
+	data.RouteIncludes, err = utils.FilterCidrs(data.RouteIncludes)
+	if err != nil {
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	data.RouteExcludes, err = utils.FilterCidrs(data.RouteExcludes)
+	if err != nil {
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	err = proxy.ValidateAddress(data.ProxyAddress)
+	if err != nil {
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	err = data.NetworkRules.Validate()
+	if err != nil {
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
 	prfl := &sprofile.Sprofile{
 		Id:                 data.Id,
 		Name:               data.Name,
@@ -121,6 +156,14 @@ func sprofilePut(c *gin.Context) {
 		DisableGateway:     data.DisableGateway,
 		DisableDns:         data.DisableDns,
 		RestrictClient:     data.RestrictClient,
+		ProxyMode:          data.ProxyMode,
+		WgUserspace:        data.WgUserspace,
+		ProxyAddress:       data.ProxyAddress,
+		RouteIncludes:      data.RouteIncludes,
+		RouteExcludes:      data.RouteExcludes,
+		SplitTunnelMode:    data.SplitTunnelMode,
+		SplitTunnelCgroups: data.SplitTunnelCgroups,
+		KillSwitch:         data.KillSwitch,
 		ForceDns:           data.ForceDns,
 		SsoAuth:            data.SsoAuth,
 		PasswordMode:       data.PasswordMode,
@@ -135,9 +178,23 @@ func sprofilePut(c *gin.Context) {
 		ServerPublicKey:    data.ServerPublicKey,
 		ServerBoxPublicKey: data.ServerBoxPublicKey,
 		RegistrationKey:    data.RegistrationKey,
+		NetworkRules:       data.NetworkRules,
 		OvpnData:           data.OvpnData,
 	}
 
+	curPrfl := sprofile.Get(prfl.Id)
+	if curPrfl != nil && curPrfl.Managed {
+		prfl.Managed = true
+		prfl.ManagedHash = curPrfl.ManagedHash
+		prfl.ManagedData = curPrfl.ManagedData
+
+		err = prfl.ApplyManaged()
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+	}
+
 	err = prfl.Commit()
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
@@ -168,6 +225,15 @@ func sprofileDel(c *gin.Context) {
 		return
 	}
 
+	sprfl := sprofile.Get(prflId)
+	if sprfl != nil && sprfl.Managed {
+		err = &errortypes.WriteError{
+			errors.New("handler: Cannot remove managed profile"),
+		}
+		utils.AbortWithError(c, 403, err)
+		return
+	}
+
 	connection.GlobalStore.SetStop(prflId)
 
 	conn := connection.GlobalStore.Get(prflId)
@@ -190,6 +256,15 @@ func sprofileDel2(c *gin.Context) {
 		return
 	}
 
+	sprfl := sprofile.Get(prflId)
+	if sprfl != nil && sprfl.Managed {
+		err := &errortypes.WriteError{
+			errors.New("handler: Cannot remove managed profile"),
+		}
+		utils.AbortWithError(c, 403, err)
+		return
+	}
+
 	connection.GlobalStore.SetStop(prflId)
 
 	conn := connection.GlobalStore.Get(prflId)
@@ -245,3 +320,47 @@ func sprofileLogDel(c *gin.Context) {
 
 	c.JSON(200, nil)
 }
+
+func sprofileCredentialsDel(c *gin.Context) {
+	prflId := utils.FilterStr(c.Param("profile_id"))
+	if prflId == "" {
+		err := &errortypes.ParseError{
+			errors.New("handler: Invalid profile ID"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	err := sprofile.ClearCredentials(prflId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, nil)
+}
+
+func sprofileHistoryGet(c *gin.Context) {
+	prflId := utils.FilterStr(c.Param("profile_id"))
+	if prflId == "" {
+		err := &errortypes.ParseError{
+			errors.New("handler: Invalid profile ID"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	sprfl := sprofile.Get(prflId)
+	if sprfl == nil {
+		utils.AbortWithStatus(c, 404)
+		return
+	}
+
+	entries, err := log.GetProfileHistory(sprfl.Id)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, entries)
+}
@@ -0,0 +1,146 @@
+package killswitch
+
+import (
+	"net"
+	"sort"
+	"sync"
+
+	"github.com/dropbox/godropbox/container/set"
+)
+
+var (
+	lock    = sync.Mutex{}
+	entries = map[string]*entry{}
+)
+
+type entry struct {
+	ifaces set.Set
+	addrs  set.Set
+}
+
+func collect() (ifaces, addrs4, addrs6 []string) {
+	ifacesSet := set.NewSet()
+	addrs4Set := set.NewSet()
+	addrs6Set := set.NewSet()
+
+	for _, ent := range entries {
+		for ifaceInf := range ent.ifaces.Iter() {
+			ifacesSet.Add(ifaceInf.(string))
+		}
+
+		for addrInf := range ent.addrs.Iter() {
+			ip := net.ParseIP(addrInf.(string))
+			if ip == nil {
+				continue
+			}
+
+			if ip.To4() != nil {
+				addrs4Set.Add(ip.String())
+			} else {
+				addrs6Set.Add(ip.String())
+			}
+		}
+	}
+
+	ifaces = []string{}
+	for ifaceInf := range ifacesSet.Iter() {
+		ifaces = append(ifaces, ifaceInf.(string))
+	}
+	sort.Strings(ifaces)
+
+	addrs4 = []string{}
+	for addrInf := range addrs4Set.Iter() {
+		addrs4 = append(addrs4, addrInf.(string))
+	}
+	sort.Strings(addrs4)
+
+	addrs6 = []string{}
+	for addrInf := range addrs6Set.Iter() {
+		addrs6 = append(addrs6, addrInf.(string))
+	}
+	sort.Strings(addrs6)
+
+	return
+}
+
+func IsActive(prflId string) bool {
+	lock.Lock()
+	defer lock.Unlock()
+
+	return entries[prflId] != nil
+}
+
+// Allow adds the interface and remote addresses to the profile's allowed
+// traffic and installs the block rules if not already active. Addresses
+// are kept until the profile is removed so reconnects can reach previously
+// resolved remotes without DNS.
+func Allow(prflId, iface string, addrs []string) (err error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	ent := entries[prflId]
+	if ent == nil {
+		ent = &entry{
+			ifaces: set.NewSet(),
+			addrs:  set.NewSet(),
+		}
+		entries[prflId] = ent
+	}
+
+	if iface != "" {
+		ent.ifaces.Add(iface)
+	}
+	for _, addr := range addrs {
+		if addr != "" {
+			ent.addrs.Add(addr)
+		}
+	}
+
+	ifaces, addrs4, addrs6 := collect()
+	err = apply(ifaces, addrs4, addrs6)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func Remove(prflId string) (err error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if entries[prflId] == nil {
+		return
+	}
+	delete(entries, prflId)
+
+	if len(entries) == 0 {
+		err = clearRules()
+		if err != nil {
+			return
+		}
+		return
+	}
+
+	ifaces, addrs4, addrs6 := collect()
+	err = apply(ifaces, addrs4, addrs6)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func Clean() (err error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	entries = map[string]*entry{}
+
+	err = clearRules()
+	if err != nil {
+		return
+	}
+
+	return
+}
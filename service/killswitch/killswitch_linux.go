@@ -0,0 +1,293 @@
+package killswitch
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	nftTable    = "pritunl_killswitch"
+	iptInChain  = "PRITUNL-KS-IN"
+	iptOutChain = "PRITUNL-KS-OUT"
+)
+
+func Supported() bool {
+	return true
+}
+
+func hasNft() bool {
+	_, err := exec.LookPath("nft")
+	return err == nil
+}
+
+func nftQuote(vals []string) (quoted []string) {
+	quoted = []string{}
+	for _, val := range vals {
+		quoted = append(quoted, fmt.Sprintf("\"%s\"", val))
+	}
+	return
+}
+
+func applyNft(ifaces, addrs4, addrs6 []string) (err error) {
+	rules := &strings.Builder{}
+
+	rules.WriteString(fmt.Sprintf("table inet %s\n", nftTable))
+	rules.WriteString(fmt.Sprintf("delete table inet %s\n", nftTable))
+	rules.WriteString(fmt.Sprintf("table inet %s {\n", nftTable))
+
+	rules.WriteString("\tchain input {\n")
+	rules.WriteString("\t\ttype filter hook input priority 0; policy drop;\n")
+	rules.WriteString("\t\tiifname \"lo\" accept\n")
+	if len(ifaces) > 0 {
+		rules.WriteString(fmt.Sprintf("\t\tiifname { %s } accept\n",
+			strings.Join(nftQuote(ifaces), ", ")))
+	}
+	if len(addrs4) > 0 {
+		rules.WriteString(fmt.Sprintf("\t\tip saddr { %s } accept\n",
+			strings.Join(addrs4, ", ")))
+	}
+	if len(addrs6) > 0 {
+		rules.WriteString(fmt.Sprintf("\t\tip6 saddr { %s } accept\n",
+			strings.Join(addrs6, ", ")))
+	}
+	rules.WriteString("\t\tct state established,related accept\n")
+	rules.WriteString("\t\tudp sport 67 udp dport 68 accept\n")
+	rules.WriteString("\t\tudp sport 547 udp dport 546 accept\n")
+	rules.WriteString("\t\ticmpv6 type { nd-router-advert, " +
+		"nd-neighbor-solicit, nd-neighbor-advert, nd-redirect } accept\n")
+	rules.WriteString("\t}\n")
+
+	rules.WriteString("\tchain output {\n")
+	rules.WriteString("\t\ttype filter hook output priority 0; policy drop;\n")
+	rules.WriteString("\t\toifname \"lo\" accept\n")
+	if len(ifaces) > 0 {
+		rules.WriteString(fmt.Sprintf("\t\toifname { %s } accept\n",
+			strings.Join(nftQuote(ifaces), ", ")))
+	}
+	if len(addrs4) > 0 {
+		rules.WriteString(fmt.Sprintf("\t\tip daddr { %s } accept\n",
+			strings.Join(addrs4, ", ")))
+	}
+	if len(addrs6) > 0 {
+		rules.WriteString(fmt.Sprintf("\t\tip6 daddr { %s } accept\n",
+			strings.Join(addrs6, ", ")))
+	}
+	rules.WriteString("\t\tudp sport 68 udp dport 67 accept\n")
+	rules.WriteString("\t\tudp sport 546 udp dport 547 accept\n")
+	rules.WriteString("\t\ticmpv6 type { nd-router-solicit, " +
+		"nd-neighbor-solicit, nd-neighbor-advert } accept\n")
+	rules.WriteString("\t}\n")
+
+	rules.WriteString("}\n")
+
+	output, err := utils.ExecInputOutputCombindLogged(
+		rules.String(), "nft", "-f", "-")
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"output": output,
+			"error":  err,
+		}).Error("killswitch: Failed to apply nftables rules")
+		return
+	}
+
+	return
+}
+
+func clearNft() (err error) {
+	_, _ = utils.ExecCombinedOutput(
+		"nft", "delete", "table", "inet", nftTable)
+	return
+}
+
+func iptablesRules(ifaces, addrs []string, ip6 bool) (
+	inRules, outRules [][]string) {
+
+	inRules = [][]string{
+		{"-i", "lo", "-j", "ACCEPT"},
+	}
+	outRules = [][]string{
+		{"-o", "lo", "-j", "ACCEPT"},
+	}
+
+	for _, iface := range ifaces {
+		inRules = append(inRules, []string{"-i", iface, "-j", "ACCEPT"})
+		outRules = append(outRules, []string{"-o", iface, "-j", "ACCEPT"})
+	}
+
+	for _, addr := range addrs {
+		inRules = append(inRules, []string{"-s", addr, "-j", "ACCEPT"})
+		outRules = append(outRules, []string{"-d", addr, "-j", "ACCEPT"})
+	}
+
+	inRules = append(inRules, []string{
+		"-m", "conntrack", "--ctstate", "ESTABLISHED,RELATED",
+		"-j", "ACCEPT",
+	})
+
+	if ip6 {
+		inRules = append(inRules, []string{
+			"-p", "udp", "--sport", "547", "--dport", "546", "-j", "ACCEPT",
+		})
+		outRules = append(outRules, []string{
+			"-p", "udp", "--sport", "546", "--dport", "547", "-j", "ACCEPT",
+		})
+
+		for _, typ := range []string{
+			"router-advertisement",
+			"neighbour-solicitation",
+			"neighbour-advertisement",
+			"redirect",
+		} {
+			inRules = append(inRules, []string{
+				"-p", "ipv6-icmp", "--icmpv6-type", typ, "-j", "ACCEPT",
+			})
+		}
+		for _, typ := range []string{
+			"router-solicitation",
+			"neighbour-solicitation",
+			"neighbour-advertisement",
+		} {
+			outRules = append(outRules, []string{
+				"-p", "ipv6-icmp", "--icmpv6-type", typ, "-j", "ACCEPT",
+			})
+		}
+	} else {
+		inRules = append(inRules, []string{
+			"-p", "udp", "--sport", "67", "--dport", "68", "-j", "ACCEPT",
+		})
+		outRules = append(outRules, []string{
+			"-p", "udp", "--sport", "68", "--dport", "67", "-j", "ACCEPT",
+		})
+	}
+
+	inRules = append(inRules, []string{"-j", "DROP"})
+	outRules = append(outRules, []string{"-j", "DROP"})
+
+	return
+}
+
+func applyIptablesChain(cmd, chain, parent string,
+	rules [][]string) (err error) {
+
+	_, _ = utils.ExecCombinedOutput(cmd, "-N", chain)
+
+	_, err = utils.ExecCombinedOutputLogged(nil, cmd, "-F", chain)
+	if err != nil {
+		return
+	}
+
+	for _, rule := range rules {
+		_, err = utils.ExecCombinedOutputLogged(
+			nil, cmd, append([]string{"-A", chain}, rule...)...)
+		if err != nil {
+			return
+		}
+	}
+
+	_, e := utils.ExecCombinedOutput(cmd, "-C", parent, "-j", chain)
+	if e != nil {
+		_, err = utils.ExecCombinedOutputLogged(
+			nil, cmd, "-I", parent, "1", "-j", chain)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func clearIptablesChain(cmd, chain, parent string) {
+	for i := 0; i < 10; i++ {
+		_, e := utils.ExecCombinedOutput(cmd, "-D", parent, "-j", chain)
+		if e != nil {
+			break
+		}
+	}
+
+	_, _ = utils.ExecCombinedOutput(cmd, "-F", chain)
+	_, _ = utils.ExecCombinedOutput(cmd, "-X", chain)
+}
+
+func applyIptables(ifaces, addrs4, addrs6 []string) (err error) {
+	inRules, outRules := iptablesRules(ifaces, addrs4, false)
+
+	err = applyIptablesChain("iptables", iptInChain, "INPUT", inRules)
+	if err != nil {
+		return
+	}
+	err = applyIptablesChain("iptables", iptOutChain, "OUTPUT", outRules)
+	if err != nil {
+		return
+	}
+
+	_, e := exec.LookPath("ip6tables")
+	if e != nil {
+		return
+	}
+
+	inRules, outRules = iptablesRules(ifaces, addrs6, true)
+
+	err = applyIptablesChain("ip6tables", iptInChain, "INPUT", inRules)
+	if err != nil {
+		return
+	}
+	err = applyIptablesChain("ip6tables", iptOutChain, "OUTPUT", outRules)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func clearIptables() (err error) {
+	for _, cmd := range []string{"iptables", "ip6tables"} {
+		_, e := exec.LookPath(cmd)
+		if e != nil {
+			continue
+		}
+
+		clearIptablesChain(cmd, iptInChain, "INPUT")
+		clearIptablesChain(cmd, iptOutChain, "OUTPUT")
+	}
+
+	return
+}
+
+func apply(ifaces, addrs4, addrs6 []string) (err error) {
+	logrus.WithFields(logrus.Fields{
+		"ifaces": ifaces,
+		"addrs4": addrs4,
+		"addrs6": addrs6,
+	}).Info("killswitch: Applying kill switch rules")
+
+	if hasNft() {
+		err = applyNft(ifaces, addrs4, addrs6)
+	} else {
+		err = applyIptables(ifaces, addrs4, addrs6)
+	}
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func clearRules() (err error) {
+	if hasNft() {
+		err = clearNft()
+		if err != nil {
+			return
+		}
+	}
+
+	err = clearIptables()
+	if err != nil {
+		return
+	}
+
+	return
+}
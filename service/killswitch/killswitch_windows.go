@@ -0,0 +1,13 @@
+package killswitch
+
+func Supported() bool {
+	return false
+}
+
+func apply(ifaces, addrs4, addrs6 []string) (err error) {
+	return
+}
+
+func clearRules() (err error) {
+	return
+}
@@ -0,0 +1,190 @@
+package log
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+const (
+	HistoryConnectAttempt   = "connect_attempt"
+	HistoryRemote           = "remote"
+	HistoryAuthorized       = "authorized"
+	HistoryConnected        = "connected"
+	HistoryDisconnected     = "disconnected"
+	HistoryAuthError        = "auth_error"
+	HistoryHandshakeTimeout = "handshake_timeout"
+	HistoryCaptivePortal    = "captive_portal"
+)
+
+var historyLock = sync.Mutex{}
+
+type HistoryEntry struct {
+	Timestamp int64   `json:"timestamp"`
+	Event     string  `json:"event"`
+	StateId   string  `json:"state_id,omitempty"`
+	Mode      string  `json:"mode,omitempty"`
+	Remote    string  `json:"remote,omitempty"`
+	Reason    string  `json:"reason,omitempty"`
+	Duration  float64 `json:"duration,omitempty"`
+}
+
+func ProfilePushHistory(prflId string, entry *HistoryEntry) (err error) {
+	historyLock.Lock()
+	defer historyLock.Unlock()
+
+	prflsPath := getPath()
+	histPth1 := filepath.Join(prflsPath, prflId+".history")
+	histPth2 := histPth1 + ".1"
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "log: Failed to marshal history entry"),
+		}
+		return
+	}
+
+	file, err := os.OpenFile(histPth1,
+		os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "log: Failed to open profile history file"),
+		}
+		return
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "log: Failed to stat profile history file"),
+		}
+		return
+	}
+
+	if stat.Size() >= 500000 {
+		file.Close()
+
+		os.Remove(histPth2)
+		err = os.Rename(histPth1, histPth2)
+		if err != nil {
+			err = &errortypes.WriteError{
+				errors.Wrap(err,
+					"log: Failed to rotate profile history file"),
+			}
+			return
+		}
+
+		file, err = os.OpenFile(histPth1,
+			os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+		if err != nil {
+			err = &errortypes.WriteError{
+				errors.Wrap(err, "log: Failed to open profile history file"),
+			}
+			return
+		}
+	}
+
+	_, err = file.Write(append(data, '\n'))
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "log: Failed to write to profile history file"),
+		}
+		return
+	}
+
+	return
+}
+
+func readHistory(pth string) (entries []*HistoryEntry, err error) {
+	entries = []*HistoryEntry{}
+
+	exists, err := utils.Exists(pth)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "log: Failed to check profile history file"),
+		}
+		return
+	}
+
+	if !exists {
+		return
+	}
+
+	file, err := os.Open(pth)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "log: Failed to open profile history file"),
+		}
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		entry := &HistoryEntry{}
+		e := json.Unmarshal(line, entry)
+		if e != nil {
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "log: Failed to read profile history file"),
+		}
+		return
+	}
+
+	return
+}
+
+func GetProfileHistory(prflId string) (entries []*HistoryEntry, err error) {
+	historyLock.Lock()
+	defer historyLock.Unlock()
+
+	prflsPath := getPath()
+	histPth := filepath.Join(prflsPath, prflId+".history")
+
+	entries, err = readHistory(histPth + ".1")
+	if err != nil {
+		return
+	}
+
+	curEntries, err := readHistory(histPth)
+	if err != nil {
+		return
+	}
+
+	entries = append(entries, curEntries...)
+
+	return
+}
+
+func ClearProfileHistory(prflId string) (err error) {
+	historyLock.Lock()
+	defer historyLock.Unlock()
+
+	prflsPath := getPath()
+	histPth := filepath.Join(prflsPath, prflId+".history")
+
+	os.Remove(histPth)
+	os.Remove(histPth + ".1")
+
+	return
+}
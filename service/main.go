@@ -18,9 +18,12 @@ import (
 	"github.com/pritunl/pritunl-client-electron/service/config"
 	"github.com/pritunl/pritunl-client-electron/service/connection"
 	"github.com/pritunl/pritunl-client-electron/service/constants"
+	"github.com/pritunl/pritunl-client-electron/service/dnsfwd"
 	"github.com/pritunl/pritunl-client-electron/service/event"
 	"github.com/pritunl/pritunl-client-electron/service/logger"
+	"github.com/pritunl/pritunl-client-electron/service/managed"
 	"github.com/pritunl/pritunl-client-electron/service/router"
+	"github.com/pritunl/pritunl-client-electron/service/secret"
 	"github.com/pritunl/pritunl-client-electron/service/setup"
 	"github.com/pritunl/pritunl-client-electron/service/tuntap"
 	"github.com/pritunl/pritunl-client-electron/service/update"
@@ -59,7 +62,15 @@ func main() {
 		constants.Development = true
 	}
 
-	err := config.Load()
+	err := secret.Init()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("main: Failed to init machine key")
+		err = nil
+	}
+
+	err = config.Load()
 	if err != nil {
 		panic(err)
 	}
@@ -139,6 +150,16 @@ func main() {
 
 	gin.SetMode(gin.ReleaseMode)
 
+	if config.Config.DnsForwarder {
+		err = dnsfwd.Start(config.Config.DnsForwarderAddress)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("main: Failed to start dns forwarder")
+			err = nil
+		}
+	}
+
 	watch.StartWatch()
 
 	err = connection.Clean()
@@ -190,6 +211,7 @@ func main() {
 		}
 	}()
 
+	managed.Start()
 	connection.WatchSystemProfiles()
 
 	if winsvc.IsWindowsService() {
@@ -233,6 +255,8 @@ func main() {
 		conn.StopWait()
 	}
 
+	dnsfwd.Stop()
+
 	if runtime.GOOS == "darwin" {
 		_ = utils.ClearScutilConnKeys()
 		_ = utils.RestoreScutilDns(true)
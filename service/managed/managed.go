@@ -0,0 +1,470 @@
+// Declarative system profiles provisioned from the managed.d directory.
+package managed
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/connection"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	reconcileLock = sync.Mutex{}
+	clientSecure  = &http.Client{
+		Transport: &http.Transport{
+			TLSHandshakeTimeout: 10 * time.Second,
+			TLSClientConfig: &tls.Config{
+				MinVersion: tls.VersionTLS12,
+			},
+		},
+		Timeout: 30 * time.Second,
+	}
+	clientInsecure = &http.Client{
+		Transport: &http.Transport{
+			TLSHandshakeTimeout: 10 * time.Second,
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+				MinVersion:         tls.VersionTLS12,
+			},
+		},
+		Timeout: 30 * time.Second,
+	}
+)
+
+// Fields that can not be pinned by the settings
+var reservedFields = map[string]bool{
+	"id":           true,
+	"managed":      true,
+	"managed_hash": true,
+	"managed_data": true,
+	"ovpn_data":    true,
+	"password":     true,
+}
+
+type Profile struct {
+	Id        string                     `json:"id"`
+	Uri       string                     `json:"uri"`
+	Data      string                     `json:"data"`
+	AutoStart *bool                      `json:"auto_start"`
+	Mode      string                     `json:"mode"`
+	Settings  map[string]json.RawMessage `json:"settings"`
+}
+
+func (p *Profile) Validate() (err error) {
+	if p.Id == "" || utils.FilterStr(p.Id) != p.Id {
+		err = &errortypes.ParseError{
+			errors.Newf("managed: Invalid profile ID '%s'", p.Id),
+		}
+		return
+	}
+
+	if (p.Uri == "") == (p.Data == "") {
+		err = &errortypes.ParseError{
+			errors.New("managed: Profile requires one of uri or data"),
+		}
+		return
+	}
+
+	if p.Mode != "" && p.Mode != connection.OvpnMode &&
+		p.Mode != connection.WgMode {
+
+		err = &errortypes.ParseError{
+			errors.Newf("managed: Invalid profile mode '%s'", p.Mode),
+		}
+		return
+	}
+
+	for key := range p.Settings {
+		if reservedFields[key] {
+			err = &errortypes.ParseError{
+				errors.Newf("managed: Setting '%s' can not be managed", key),
+			}
+			return
+		}
+	}
+
+	return
+}
+
+func (p *Profile) Hash() string {
+	data, _ := json.Marshal(p)
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// ManagedData returns the pinned profile fields
+func (p *Profile) ManagedData() (data string, err error) {
+	fields := map[string]json.RawMessage{}
+
+	for key, val := range p.Settings {
+		fields[key] = val
+	}
+
+	if p.Mode != "" {
+		fields["last_mode"], _ = json.Marshal(p.Mode)
+	}
+
+	if p.AutoStart != nil {
+		fields["disabled"], _ = json.Marshal(!*p.AutoStart)
+	}
+
+	dataByt, err := json.Marshal(fields)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "managed: Failed to marshal managed data"),
+		}
+		return
+	}
+
+	data = string(dataByt)
+	return
+}
+
+func (p *Profile) Fetch() (data string, err error) {
+	if p.Data != "" {
+		data = p.Data
+		return
+	}
+
+	uri := strings.Replace(p.Uri, "pritunl://", "https://", 1)
+	uri = strings.Replace(uri, "/k/", "/ku/", 1)
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "managed: Failed to parse profile uri"),
+		}
+		return
+	}
+
+	client := clientSecure
+	if net.ParseIP(u.Hostname()) != nil {
+		client = clientInsecure
+	}
+
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "managed: Profile uri request error"),
+		}
+		return
+	}
+	req.Header.Set("User-Agent", "pritunl")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "managed: Profile uri request failed"),
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		err = &errortypes.RequestError{
+			errors.Newf("managed: Profile uri request error %d",
+				resp.StatusCode),
+		}
+		return
+	}
+
+	prfls := map[string]string{}
+	err = json.NewDecoder(resp.Body).Decode(&prfls)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "managed: Failed to parse uri response body"),
+		}
+		return
+	}
+
+	if len(prfls) != 1 {
+		err = &errortypes.ParseError{
+			errors.Newf("managed: Profile uri returned %d profiles, "+
+				"expected one", len(prfls)),
+		}
+		return
+	}
+
+	for _, prflData := range prfls {
+		data = prflData
+	}
+
+	return
+}
+
+// load reads all managed profiles, complete is false if any file could not
+// be loaded and profiles missing from the directory must not be removed
+func load() (prfls []*Profile, complete bool, err error) {
+	prfls = []*Profile{}
+	complete = true
+	prflIds := map[string]string{}
+
+	managedPath := config.GetManagedPath()
+
+	files, err := ioutil.ReadDir(managedPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "managed: Failed to read managed directory"),
+		}
+		return
+	}
+
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		pth := filepath.Join(managedPath, name)
+
+		filePrfls, e := loadFile(pth)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"path":  pth,
+				"error": e,
+			}).Error("managed: Failed to load managed profiles")
+			complete = false
+			continue
+		}
+
+		for _, prfl := range filePrfls {
+			if prflIds[prfl.Id] != "" {
+				logrus.WithFields(logrus.Fields{
+					"profile_id": prfl.Id,
+					"path":       pth,
+					"other_path": prflIds[prfl.Id],
+				}).Error("managed: Duplicate managed profile ID")
+				complete = false
+				continue
+			}
+			prflIds[prfl.Id] = pth
+
+			prfls = append(prfls, prfl)
+		}
+	}
+
+	return
+}
+
+func loadFile(pth string) (prfls []*Profile, err error) {
+	data, err := ioutil.ReadFile(pth)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "managed: Failed to read managed file"),
+		}
+		return
+	}
+
+	data = []byte(strings.TrimSpace(string(data)))
+	if len(data) > 0 && data[0] == '[' {
+		err = json.Unmarshal(data, &prfls)
+	} else {
+		prfl := &Profile{}
+		err = json.Unmarshal(data, prfl)
+		prfls = []*Profile{prfl}
+	}
+	if err != nil {
+		prfls = nil
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "managed: Failed to parse managed file"),
+		}
+		return
+	}
+
+	for _, prfl := range prfls {
+		err = prfl.Validate()
+		if err != nil {
+			prfls = nil
+			return
+		}
+	}
+
+	return
+}
+
+func provision(prfl *Profile, hash string,
+	curPrfl *sprofile.Sprofile) (err error) {
+
+	data, err := prfl.Fetch()
+	if err != nil {
+		return
+	}
+
+	sprfl, err := sprofile.Parse(prfl.Id, data)
+	if err != nil {
+		return
+	}
+
+	managedData, err := prfl.ManagedData()
+	if err != nil {
+		return
+	}
+
+	if curPrfl != nil {
+		sprfl.Password = curPrfl.Password
+		if prfl.Mode == "" {
+			sprfl.LastMode = curPrfl.LastMode
+		}
+	}
+
+	sprfl.Managed = true
+	sprfl.ManagedHash = hash
+	sprfl.ManagedData = managedData
+
+	err = sprfl.ApplyManaged()
+	if err != nil {
+		return
+	}
+
+	err = sprfl.Commit()
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func Reconcile() (err error) {
+	reconcileLock.Lock()
+	defer reconcileLock.Unlock()
+
+	prfls, complete, err := load()
+	if err != nil {
+		return
+	}
+
+	sprfls, err := sprofile.GetAll()
+	if err != nil {
+		return
+	}
+
+	curPrfls := map[string]*sprofile.Sprofile{}
+	for _, sprfl := range sprfls {
+		curPrfls[sprfl.Id] = sprfl
+	}
+
+	prflIds := map[string]bool{}
+	starts := []*Profile{}
+	stops := []*Profile{}
+	changed := false
+
+	for _, prfl := range prfls {
+		prflIds[prfl.Id] = true
+		hash := prfl.Hash()
+
+		curPrfl := curPrfls[prfl.Id]
+		if curPrfl != nil && curPrfl.Managed && curPrfl.ManagedHash == hash {
+			continue
+		}
+
+		e := provision(prfl, hash, curPrfl)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": prfl.Id,
+				"error":      e,
+			}).Error("managed: Failed to provision managed profile")
+			continue
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"profile_id": prfl.Id,
+			"created":    curPrfl == nil,
+		}).Info("managed: Provisioned managed profile")
+
+		changed = true
+		if curPrfl == nil {
+			starts = append(starts, prfl)
+		} else if prfl.AutoStart != nil &&
+			curPrfl.Disabled == *prfl.AutoStart {
+
+			if *prfl.AutoStart {
+				starts = append(starts, prfl)
+			} else {
+				stops = append(stops, prfl)
+			}
+		}
+	}
+
+	if complete {
+		for _, sprfl := range sprfls {
+			if !sprfl.Managed || prflIds[sprfl.Id] {
+				continue
+			}
+
+			logrus.WithFields(logrus.Fields{
+				"profile_id": sprfl.Id,
+			}).Info("managed: Removing unmanaged profile")
+
+			connection.GlobalStore.SetStop(sprfl.Id)
+			conn := connection.GlobalStore.Get(sprfl.Id)
+			if conn != nil {
+				conn.Stop()
+			}
+
+			sprofile.Remove(sprfl.Id)
+			changed = true
+		}
+	}
+
+	if !changed {
+		return
+	}
+
+	err = sprofile.Reload(false)
+	if err != nil {
+		return
+	}
+
+	for _, prfl := range stops {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": prfl.Id,
+		}).Info("managed: Stopping managed profile without auto start")
+
+		connection.GlobalStore.SetStop(prfl.Id)
+		conn := connection.GlobalStore.Get(prfl.Id)
+		if conn != nil {
+			conn.Stop()
+		}
+
+		sprofile.Deactivate(prfl.Id)
+	}
+
+	for _, prfl := range starts {
+		if prfl.AutoStart == nil || !*prfl.AutoStart {
+			continue
+		}
+
+		sprfl := sprofile.Get(prfl.Id)
+		if sprfl == nil {
+			continue
+		}
+
+		err = sprofile.Activate(sprfl.Id, sprfl.LastMode, sprfl.Password)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
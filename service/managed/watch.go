@@ -0,0 +1,98 @@
+package managed
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/platform"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	reconcileDelay = 1 * time.Second
+)
+
+func reconcile() {
+	err := Reconcile()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("managed: Failed to reconcile managed profiles")
+	}
+}
+
+func watchManaged() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"trace": string(debug.Stack()),
+				"panic": panc,
+			}).Error("managed: Watch managed panic")
+			time.Sleep(10 * time.Second)
+			go watchManaged()
+		}
+	}()
+
+	managedPath := config.GetManagedPath()
+
+	err := platform.MkdirSecure(managedPath)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("managed: Failed to create managed directory")
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err == nil {
+		err = watcher.Add(managedPath)
+		if err != nil {
+			_ = watcher.Close()
+		}
+	}
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("managed: Failed to watch managed directory")
+
+		reconcile()
+		return
+	}
+	defer watcher.Close()
+
+	reconcile()
+
+	pending := time.NewTimer(reconcileDelay)
+	pending.Stop()
+
+	for {
+		select {
+		case evt, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			if evt.Op == fsnotify.Chmod {
+				continue
+			}
+
+			pending.Reset(reconcileDelay)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("managed: Managed watcher error")
+		case <-pending.C:
+			reconcile()
+		}
+	}
+}
+
+func Start() {
+	go watchManaged()
+}
@@ -0,0 +1,210 @@
+// Prometheus text format exporter for connection health.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	reconnectsName        = "pritunl_client_reconnects_total"
+	authFailuresName      = "pritunl_client_auth_failures_total"
+	keepaliveFailuresName = "pritunl_client_keepalive_failures_total"
+	connectDurationName   = "pritunl_client_connect_duration_seconds"
+	handshakeAgeName      = "pritunl_client_wg_handshake_age_seconds"
+	connectionsName       = "pritunl_client_connections"
+	connectedName         = "pritunl_client_connected"
+	profileStatusName     = "pritunl_client_profile_status"
+	uptimeName            = "pritunl_client_uptime_seconds"
+)
+
+var (
+	lock              = sync.Mutex{}
+	reconnects        = map[string]uint64{}
+	authFailures      = map[string]uint64{}
+	keepaliveFailures = map[string]uint64{}
+	connectDurations  = map[string]float64{}
+	handshakes        = map[string]int64{}
+)
+
+type ProfileState struct {
+	Id        string
+	Mode      string
+	Status    string
+	Timestamp int64
+}
+
+func ProfileReconnect(prflId string) {
+	lock.Lock()
+	reconnects[prflId] += 1
+	lock.Unlock()
+}
+
+func ProfileAuthFailure(prflId string) {
+	lock.Lock()
+	authFailures[prflId] += 1
+	lock.Unlock()
+}
+
+func ProfileKeepaliveFailure(prflId string) {
+	lock.Lock()
+	keepaliveFailures[prflId] += 1
+	lock.Unlock()
+}
+
+func ProfileConnectDuration(prflId string, duration time.Duration) {
+	lock.Lock()
+	connectDurations[prflId] = duration.Seconds()
+	lock.Unlock()
+}
+
+func ProfileHandshake(prflId string, timestamp int64) {
+	lock.Lock()
+	if timestamp == 0 {
+		delete(handshakes, prflId)
+	} else {
+		handshakes[prflId] = timestamp
+	}
+	lock.Unlock()
+}
+
+// ProfileRemove deletes the series of a stopped profile
+func ProfileRemove(prflId string) {
+	lock.Lock()
+	delete(reconnects, prflId)
+	delete(authFailures, prflId)
+	delete(keepaliveFailures, prflId)
+	delete(connectDurations, prflId)
+	delete(handshakes, prflId)
+	lock.Unlock()
+}
+
+func escape(val string) string {
+	val = strings.ReplaceAll(val, "\\", "\\\\")
+	val = strings.ReplaceAll(val, "\n", "\\n")
+	val = strings.ReplaceAll(val, "\"", "\\\"")
+	return val
+}
+
+func writeHeader(w io.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, typ)
+}
+
+func writeValue(w io.Writer, name string, labels [][2]string,
+	val float64) {
+
+	if len(labels) == 0 {
+		fmt.Fprintf(w, "%s %v\n", name, val)
+		return
+	}
+
+	lbls := []string{}
+	for _, label := range labels {
+		lbls = append(lbls, fmt.Sprintf(
+			"%s=\"%s\"", label[0], escape(label[1])))
+	}
+
+	fmt.Fprintf(w, "%s{%s} %v\n", name, strings.Join(lbls, ","), val)
+}
+
+func writeCounter(w io.Writer, name, help string, vals map[string]uint64) {
+	prflIds := []string{}
+	for prflId := range vals {
+		prflIds = append(prflIds, prflId)
+	}
+	sort.Strings(prflIds)
+
+	writeHeader(w, name, help, "counter")
+	for _, prflId := range prflIds {
+		writeValue(w, name, [][2]string{
+			{"profile_id", prflId},
+		}, float64(vals[prflId]))
+	}
+}
+
+func Write(w io.Writer, states []*ProfileState) {
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Id < states[j].Id
+	})
+
+	connected := 0
+	for _, state := range states {
+		if state.Status == "connected" {
+			connected += 1
+		}
+	}
+
+	writeHeader(w, connectionsName,
+		"Number of active connections", "gauge")
+	writeValue(w, connectionsName, nil, float64(len(states)))
+
+	writeHeader(w, connectedName,
+		"Number of connected connections", "gauge")
+	writeValue(w, connectedName, nil, float64(connected))
+
+	writeHeader(w, profileStatusName,
+		"Current status of each active profile", "gauge")
+	for _, state := range states {
+		writeValue(w, profileStatusName, [][2]string{
+			{"profile_id", state.Id},
+			{"mode", state.Mode},
+			{"status", state.Status},
+		}, 1)
+	}
+
+	now := time.Now().Unix()
+
+	writeHeader(w, uptimeName,
+		"Seconds since profile connected", "gauge")
+	for _, state := range states {
+		if state.Timestamp == 0 {
+			continue
+		}
+		writeValue(w, uptimeName, [][2]string{
+			{"profile_id", state.Id},
+		}, float64(now-state.Timestamp))
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	prflIds := []string{}
+	for prflId := range connectDurations {
+		prflIds = append(prflIds, prflId)
+	}
+	sort.Strings(prflIds)
+
+	writeHeader(w, connectDurationName,
+		"Seconds taken to establish the last connection", "gauge")
+	for _, prflId := range prflIds {
+		writeValue(w, connectDurationName, [][2]string{
+			{"profile_id", prflId},
+		}, connectDurations[prflId])
+	}
+
+	prflIds = []string{}
+	for prflId := range handshakes {
+		prflIds = append(prflIds, prflId)
+	}
+	sort.Strings(prflIds)
+
+	writeHeader(w, handshakeAgeName,
+		"Seconds since the last WireGuard handshake", "gauge")
+	for _, prflId := range prflIds {
+		writeValue(w, handshakeAgeName, [][2]string{
+			{"profile_id", prflId},
+		}, float64(now-handshakes[prflId]))
+	}
+
+	writeCounter(w, reconnectsName,
+		"Total automatic reconnects", reconnects)
+	writeCounter(w, authFailuresName,
+		"Total authentication failures", authFailures)
+	writeCounter(w, keepaliveFailuresName,
+		"Total WireGuard keepalive failures", keepaliveFailures)
+}
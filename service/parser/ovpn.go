@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"net"
 	"strconv"
 	"strings"
 
@@ -67,6 +68,34 @@ type Ovpn struct {
 
 	DisableGateway bool
 	DisableDns     bool
+	RouteIncludes  []string
+	RouteExcludes  []string
+	RouteNoPull    bool
+}
+
+func (o *Ovpn) exportRoute(cidr string, netGateway bool) string {
+	ip, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"network": cidr,
+		}).Warn("parser: Invalid route network ignored")
+		return ""
+	}
+
+	gateway := ""
+	if netGateway {
+		gateway = " net_gateway"
+	}
+
+	if ip.To4() == nil {
+		return fmt.Sprintf("route-ipv6 %s%s\n", network.String(), gateway)
+	}
+
+	if !netGateway {
+		gateway = " vpn_gateway"
+	}
+	return fmt.Sprintf("route %s %s%s\n", network.IP.String(),
+		net.IP(network.Mask).String(), gateway)
 }
 
 func (o *Ovpn) Export() string {
@@ -126,7 +155,7 @@ func (o *Ovpn) Export() string {
 	if o.RenegSec > 0 {
 		output += fmt.Sprintf("reneg-sec %d\n", o.RenegSec)
 	}
-	if o.RedirectGateway != "" {
+	if o.RedirectGateway != "" && !o.RouteNoPull {
 		output += fmt.Sprintf("redirect-gateway %s\n", o.RedirectGateway)
 	}
 	if o.SndBuf > 0 {
@@ -170,6 +199,17 @@ func (o *Ovpn) Export() string {
 		output += "pull-filter ignore \"dhcp-option\"\n"
 	}
 
+	if o.RouteNoPull {
+		output += "route-nopull\n"
+	} else {
+		for _, cidr := range o.RouteIncludes {
+			output += o.exportRoute(cidr, false)
+		}
+		for _, cidr := range o.RouteExcludes {
+			output += o.exportRoute(cidr, true)
+		}
+	}
+
 	output += "pull-filter ignore \"ping-restart\"\n"
 
 	output += "ignore-unknown-option data-ciphers\n"
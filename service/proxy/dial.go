@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"context"
+	"net"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+func findInterface(ip net.IP) (iface *net.Interface, err error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "proxy: Failed to list interfaces"),
+		}
+		return
+	}
+
+	for i := range ifaces {
+		addrs, e := ifaces[i].Addrs()
+		if e != nil {
+			continue
+		}
+
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if ok && ipNet.IP.Equal(ip) {
+				iface = &ifaces[i]
+				return
+			}
+		}
+	}
+
+	err = &errortypes.NotFoundError{
+		errors.Newf("proxy: Failed to find interface for '%s'", ip),
+	}
+	return
+}
+
+func tunnelResolver(iface *net.Interface,
+	dnsServers []string) *net.Resolver {
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, addr string) (
+			conn net.Conn, err error) {
+
+			for _, server := range dnsServers {
+				ip := net.ParseIP(server)
+				if ip == nil {
+					continue
+				}
+
+				dialer := &net.Dialer{
+					Control: bindControl(iface, ip.To4() != nil),
+				}
+
+				conn, err = dialer.DialContext(ctx, network,
+					net.JoinHostPort(server, "53"))
+				if err == nil {
+					return
+				}
+			}
+
+			if err == nil {
+				err = &errortypes.NotFoundError{
+					errors.New("proxy: No valid tunnel dns servers"),
+				}
+			}
+			return
+		},
+	}
+}
+
+func BoundDial(localAddr string, dnsServers []string) DialFunc {
+	return func(ctx context.Context, network, addr string) (
+		conn net.Conn, err error) {
+
+		ip := net.ParseIP(localAddr)
+		if ip == nil {
+			err = &errortypes.ParseError{
+				errors.Newf("proxy: Invalid local address '%s'", localAddr),
+			}
+			return
+		}
+
+		iface, err := findInterface(ip)
+		if err != nil {
+			return
+		}
+
+		dialer := &net.Dialer{
+			LocalAddr: &net.TCPAddr{
+				IP: ip,
+			},
+			Control: bindControl(iface, ip.To4() != nil),
+		}
+
+		if len(dnsServers) > 0 {
+			dialer.Resolver = tunnelResolver(iface, dnsServers)
+		}
+
+		conn, err = dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return
+		}
+
+		return
+	}
+}
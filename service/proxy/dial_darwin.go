@@ -0,0 +1,28 @@
+package proxy
+
+import (
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func bindControl(iface *net.Interface, ip4 bool) func(
+	network, address string, c syscall.RawConn) error {
+
+	return func(network, address string, c syscall.RawConn) (err error) {
+		e := c.Control(func(fd uintptr) {
+			if ip4 {
+				err = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP,
+					unix.IP_BOUND_IF, iface.Index)
+			} else {
+				err = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6,
+					unix.IPV6_BOUND_IF, iface.Index)
+			}
+		})
+		if e != nil {
+			err = e
+		}
+		return
+	}
+}
@@ -0,0 +1,23 @@
+package proxy
+
+import (
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func bindControl(iface *net.Interface, ip4 bool) func(
+	network, address string, c syscall.RawConn) error {
+
+	return func(network, address string, c syscall.RawConn) (err error) {
+		e := c.Control(func(fd uintptr) {
+			err = unix.SetsockoptString(int(fd), unix.SOL_SOCKET,
+				unix.SO_BINDTODEVICE, iface.Name)
+		})
+		if e != nil {
+			err = e
+		}
+		return
+	}
+}
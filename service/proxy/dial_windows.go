@@ -0,0 +1,38 @@
+package proxy
+
+import (
+	"encoding/binary"
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	ipUnicastIf   = 31
+	ipv6UnicastIf = 31
+)
+
+func bindControl(iface *net.Interface, ip4 bool) func(
+	network, address string, c syscall.RawConn) error {
+
+	return func(network, address string, c syscall.RawConn) (err error) {
+		e := c.Control(func(fd uintptr) {
+			if ip4 {
+				index := make([]byte, 4)
+				binary.BigEndian.PutUint32(index, uint32(iface.Index))
+
+				err = windows.SetsockoptInt(windows.Handle(fd),
+					windows.IPPROTO_IP, ipUnicastIf,
+					int(binary.LittleEndian.Uint32(index)))
+			} else {
+				err = windows.SetsockoptInt(windows.Handle(fd),
+					windows.IPPROTO_IPV6, ipv6UnicastIf, iface.Index)
+			}
+		})
+		if e != nil {
+			err = e
+		}
+		return
+	}
+}
@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+func httpError(conn net.Conn, code int) {
+	_, _ = fmt.Fprintf(conn, "HTTP/1.1 %d %s\r\nConnection: close\r\n\r\n",
+		code, http.StatusText(code))
+}
+
+func (p *Proxy) handleHttp(conn net.Conn, reader *bufio.Reader) (
+	err error) {
+
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		httpError(conn, http.StatusBadRequest)
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "proxy: Failed to read http request"),
+		}
+		return
+	}
+
+	if req.Method == http.MethodConnect {
+		remote, e := p.dial(req.Host)
+		if e != nil {
+			httpError(conn, http.StatusBadGateway)
+			err = e
+			return
+		}
+
+		_, err = conn.Write(
+			[]byte("HTTP/1.1 200 Connection Established\r\n\r\n"))
+		if err != nil {
+			_ = remote.Close()
+			err = &errortypes.WriteError{
+				errors.Wrap(err, "proxy: Failed to write http response"),
+			}
+			return
+		}
+
+		p.pipe(conn, reader, remote)
+		return
+	}
+
+	if req.URL.Scheme != "http" || req.URL.Host == "" {
+		httpError(conn, http.StatusBadRequest)
+		err = &errortypes.RequestError{
+			errors.Newf("proxy: Unsupported http request '%s'", req.URL),
+		}
+		return
+	}
+
+	addr := req.URL.Host
+	if _, _, e := net.SplitHostPort(addr); e != nil {
+		addr = net.JoinHostPort(strings.Trim(addr, "[]"), "80")
+	}
+
+	remote, err := p.dial(addr)
+	if err != nil {
+		httpError(conn, http.StatusBadGateway)
+		return
+	}
+
+	for key := range req.Header {
+		if strings.HasPrefix(strings.ToLower(key), "proxy-") {
+			req.Header.Del(key)
+		}
+	}
+	req.Close = true
+
+	err = req.Write(remote)
+	if err != nil {
+		_ = remote.Close()
+		httpError(conn, http.StatusBadGateway)
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "proxy: Failed to forward http request"),
+		}
+		return
+	}
+
+	p.pipe(conn, reader, remote)
+
+	return
+}
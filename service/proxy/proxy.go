@@ -0,0 +1,257 @@
+// Local SOCKS5 and HTTP proxy served on a single listener.
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultAddress   = "127.0.0.1:0"
+	handshakeTimeout = 30 * time.Second
+	dialTimeout      = 30 * time.Second
+)
+
+type DialFunc func(ctx context.Context, network, addr string) (
+	net.Conn, error)
+
+func ValidateAddress(addr string) (err error) {
+	if addr == "" {
+		return
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "proxy: Invalid proxy address"),
+		}
+		return
+	}
+
+	if host == "localhost" {
+		return
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !ip.IsLoopback() {
+		err = &errortypes.ParseError{
+			errors.New("proxy: Proxy address must be loopback"),
+		}
+		return
+	}
+
+	return
+}
+
+type Proxy struct {
+	Address  string
+	Dial     DialFunc
+	lock     sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+}
+
+func (p *Proxy) Start() (err error) {
+	if p.Address == "" {
+		p.Address = DefaultAddress
+	}
+
+	err = ValidateAddress(p.Address)
+	if err != nil {
+		return
+	}
+
+	if p.Dial == nil {
+		dialer := &net.Dialer{}
+		p.Dial = dialer.DialContext
+	}
+
+	listener, err := net.Listen("tcp", p.Address)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "proxy: Failed to listen"),
+		}
+		return
+	}
+
+	p.lock.Lock()
+	p.Address = listener.Addr().String()
+	p.listener = listener
+	p.conns = map[net.Conn]struct{}{}
+	p.lock.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"address": listener.Addr().String(),
+	}).Info("proxy: Proxy server started")
+
+	go p.serve(listener)
+
+	return
+}
+
+func (p *Proxy) Close() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.closed {
+		return
+	}
+	p.closed = true
+
+	if p.listener != nil {
+		_ = p.listener.Close()
+	}
+
+	for conn := range p.conns {
+		_ = conn.Close()
+	}
+	p.conns = map[net.Conn]struct{}{}
+}
+
+func (p *Proxy) track(conn net.Conn) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.closed {
+		return false
+	}
+
+	p.conns[conn] = struct{}{}
+	return true
+}
+
+func (p *Proxy) untrack(conn net.Conn) {
+	p.lock.Lock()
+	delete(p.conns, conn)
+	p.lock.Unlock()
+}
+
+func (p *Proxy) serve(listener net.Listener) {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"trace": string(debug.Stack()),
+				"panic": panc,
+			}).Error("proxy: Serve panic")
+		}
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			p.lock.Lock()
+			closed := p.closed
+			p.lock.Unlock()
+
+			if closed {
+				return
+			}
+
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("proxy: Failed to accept connection")
+
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
+		go p.handle(conn)
+	}
+}
+
+func (p *Proxy) handle(conn net.Conn) {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"trace": string(debug.Stack()),
+				"panic": panc,
+			}).Error("proxy: Handle panic")
+		}
+	}()
+	defer conn.Close()
+
+	if !p.track(conn) {
+		return
+	}
+	defer p.untrack(conn)
+
+	_ = conn.SetDeadline(time.Now().Add(handshakeTimeout))
+
+	reader := bufio.NewReader(conn)
+	head, err := reader.Peek(1)
+	if err != nil {
+		return
+	}
+
+	if head[0] == socksVersion {
+		err = p.handleSocks(conn, reader)
+	} else {
+		err = p.handleHttp(conn, reader)
+	}
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"client": conn.RemoteAddr().String(),
+			"error":  err,
+		}).Warn("proxy: Proxy request failed")
+	}
+}
+
+func (p *Proxy) dial(addr string) (conn net.Conn, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+
+	conn, err = p.Dial(ctx, "tcp", addr)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrapf(err, "proxy: Failed to dial '%s'", addr),
+		}
+		return
+	}
+
+	return
+}
+
+func (p *Proxy) pipe(client net.Conn, reader io.Reader, remote net.Conn) {
+	if !p.track(remote) {
+		_ = remote.Close()
+		return
+	}
+	defer p.untrack(remote)
+
+	_ = client.SetDeadline(time.Time{})
+
+	done := make(chan struct{}, 2)
+
+	go func() {
+		_, _ = io.Copy(remote, reader)
+		if tcpConn, ok := remote.(interface{ CloseWrite() error }); ok {
+			_ = tcpConn.CloseWrite()
+		}
+		done <- struct{}{}
+	}()
+
+	go func() {
+		_, _ = io.Copy(client, remote)
+		if tcpConn, ok := client.(interface{ CloseWrite() error }); ok {
+			_ = tcpConn.CloseWrite()
+		}
+		done <- struct{}{}
+	}()
+
+	<-done
+	<-done
+
+	_ = remote.Close()
+}
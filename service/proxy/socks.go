@@ -0,0 +1,172 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+const (
+	socksVersion       = 0x05
+	socksAuthNone      = 0x00
+	socksAuthNoMethods = 0xff
+	socksCmdConnect    = 0x01
+	socksAddrIp4       = 0x01
+	socksAddrDomain    = 0x03
+	socksAddrIp6       = 0x04
+	socksReplySuccess  = 0x00
+	socksReplyFailure  = 0x01
+	socksReplyHost     = 0x04
+	socksReplyCommand  = 0x07
+	socksReplyAddrType = 0x08
+)
+
+func socksReply(conn net.Conn, code byte) (err error) {
+	_, err = conn.Write([]byte{
+		socksVersion, code, 0x00, socksAddrIp4,
+		0, 0, 0, 0, 0, 0,
+	})
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "proxy: Failed to write socks reply"),
+		}
+		return
+	}
+
+	return
+}
+
+func (p *Proxy) handleSocks(conn net.Conn, reader *bufio.Reader) (
+	err error) {
+
+	header := make([]byte, 2)
+	_, err = io.ReadFull(reader, header)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "proxy: Failed to read socks header"),
+		}
+		return
+	}
+
+	methods := make([]byte, int(header[1]))
+	_, err = io.ReadFull(reader, methods)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "proxy: Failed to read socks methods"),
+		}
+		return
+	}
+
+	authNone := false
+	for _, method := range methods {
+		if method == socksAuthNone {
+			authNone = true
+			break
+		}
+	}
+
+	if !authNone {
+		_, _ = conn.Write([]byte{socksVersion, socksAuthNoMethods})
+		err = &errortypes.RequestError{
+			errors.New("proxy: No supported socks auth method"),
+		}
+		return
+	}
+
+	_, err = conn.Write([]byte{socksVersion, socksAuthNone})
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "proxy: Failed to write socks method"),
+		}
+		return
+	}
+
+	request := make([]byte, 4)
+	_, err = io.ReadFull(reader, request)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "proxy: Failed to read socks request"),
+		}
+		return
+	}
+
+	if request[0] != socksVersion {
+		err = &errortypes.RequestError{
+			errors.New("proxy: Invalid socks version"),
+		}
+		return
+	}
+
+	host := ""
+	switch request[3] {
+	case socksAddrIp4:
+		addr := make([]byte, net.IPv4len)
+		_, err = io.ReadFull(reader, addr)
+		host = net.IP(addr).String()
+	case socksAddrIp6:
+		addr := make([]byte, net.IPv6len)
+		_, err = io.ReadFull(reader, addr)
+		host = net.IP(addr).String()
+	case socksAddrDomain:
+		var length byte
+		length, err = reader.ReadByte()
+		if err == nil {
+			addr := make([]byte, int(length))
+			_, err = io.ReadFull(reader, addr)
+			host = string(addr)
+		}
+	default:
+		_ = socksReply(conn, socksReplyAddrType)
+		err = &errortypes.RequestError{
+			errors.New("proxy: Unsupported socks address type"),
+		}
+		return
+	}
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "proxy: Failed to read socks address"),
+		}
+		return
+	}
+
+	port := make([]byte, 2)
+	_, err = io.ReadFull(reader, port)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "proxy: Failed to read socks port"),
+		}
+		return
+	}
+
+	if request[1] != socksCmdConnect {
+		_ = socksReply(conn, socksReplyCommand)
+		err = &errortypes.RequestError{
+			errors.New("proxy: Unsupported socks command"),
+		}
+		return
+	}
+
+	addr := net.JoinHostPort(host,
+		strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+
+	remote, err := p.dial(addr)
+	if err != nil {
+		_ = socksReply(conn, socksReplyHost)
+		return
+	}
+
+	err = socksReply(conn, socksReplySuccess)
+	if err != nil {
+		_ = remote.Close()
+		return
+	}
+
+	p.pipe(conn, reader, remote)
+
+	return
+}
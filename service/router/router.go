@@ -10,12 +10,15 @@ import (
 
 	"github.com/dropbox/godropbox/errors"
 	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/config"
 	"github.com/pritunl/pritunl-client-electron/service/errortypes"
 	"github.com/pritunl/pritunl-client-electron/service/handlers"
+	"github.com/sirupsen/logrus"
 )
 
 type Router struct {
-	server *http.Server
+	server        *http.Server
+	metricsServer *http.Server
 }
 
 func (r *Router) runSock() (err error) {
@@ -60,7 +63,23 @@ func (r *Router) runTcp() (err error) {
 	return
 }
 
+func (r *Router) runMetrics() {
+	err := r.metricsServer.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "main: Metrics server listen error"),
+		}
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("router: Failed to start metrics server")
+	}
+}
+
 func (r *Router) Run() (err error) {
+	if r.metricsServer != nil {
+		go r.runMetrics()
+	}
+
 	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
 		err = r.runTcp()
 		if err != nil {
@@ -87,6 +106,11 @@ func (r *Router) Shutdown() {
 	)
 	defer webCancel()
 
+	if r.metricsServer != nil {
+		_ = r.metricsServer.Shutdown(webCtx)
+		_ = r.metricsServer.Close()
+	}
+
 	_ = r.server.Shutdown(webCtx)
 	_ = r.server.Close()
 }
@@ -103,5 +127,23 @@ func (r *Router) Init() {
 		MaxHeaderBytes: 4096,
 	}
 
+	if config.Config.EnableMetrics {
+		addr := config.Config.MetricsAddress
+		if addr == "" {
+			addr = "127.0.0.1:9690"
+		}
+
+		metricsRouter := gin.New()
+		handlers.RegisterMetrics(metricsRouter)
+
+		r.metricsServer = &http.Server{
+			Addr:           addr,
+			Handler:        metricsRouter,
+			ReadTimeout:    30 * time.Second,
+			WriteTimeout:   30 * time.Second,
+			MaxHeaderBytes: 4096,
+		}
+	}
+
 	return
 }
@@ -0,0 +1,252 @@
+package secret
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/platform"
+	"github.com/pritunl/pritunl-client-electron/service/tpm"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	KeyTpm  = "tpm"
+	KeyFile = "file"
+)
+
+var (
+	key     []byte
+	keyLock = sync.Mutex{}
+)
+
+type keyData struct {
+	Type string `json:"type"`
+	Data string `json:"data"`
+}
+
+func GetKeyPath() string {
+	switch runtime.GOOS {
+	case "windows":
+		return filepath.Join(utils.GetWinDrive(), "ProgramData",
+			"Pritunl", "Keys", "secret.key")
+	case "darwin":
+		return filepath.Join("/", "Library",
+			"Application Support", "Pritunl", "Keys", "secret.key")
+	case "linux":
+		return filepath.Join("/", "etc", "pritunl-client", "secret.key")
+	default:
+		panic("secret: Not implemented")
+	}
+}
+
+// migrateKey moves the key file from the previous location in the data
+// directory which is not restricted to root
+func migrateKey(pth string) (err error) {
+	if runtime.GOOS != "linux" {
+		return
+	}
+
+	oldPth := filepath.Join("/", "var",
+		"lib", "pritunl-client", "keys", "secret.key")
+
+	exists, err := utils.ExistsFile(oldPth)
+	if err != nil || !exists {
+		return
+	}
+
+	err = platform.MkdirSecure(filepath.Dir(pth))
+	if err != nil {
+		return
+	}
+
+	err = utils.Copy(oldPth, pth)
+	if err != nil {
+		return
+	}
+
+	err = os.Chmod(pth, 0600)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "secret: Failed to chmod key file"),
+		}
+		return
+	}
+
+	_ = os.RemoveAll(filepath.Dir(oldPth))
+
+	logrus.WithFields(logrus.Fields{
+		"old_path": oldPth,
+		"new_path": pth,
+	}).Info("secret: Moved machine key")
+
+	return
+}
+
+func loadKey(pth string) (newKey []byte, err error) {
+	data, err := ioutil.ReadFile(pth)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "secret: Failed to read key file"),
+		}
+		return
+	}
+
+	keyDt := &keyData{}
+	err = json.Unmarshal(data, keyDt)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "secret: Failed to parse key file"),
+		}
+		return
+	}
+
+	keyByt, err := base64.StdEncoding.DecodeString(keyDt.Data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "secret: Failed to decode key file"),
+		}
+		return
+	}
+
+	switch keyDt.Type {
+	case KeyTpm:
+		newKey, err = tpm.Unseal(keyByt)
+		if err != nil {
+			return
+		}
+	case KeyFile:
+		newKey = keyByt
+	default:
+		err = &errortypes.ParseError{
+			errors.Newf("secret: Unknown key type '%s'", keyDt.Type),
+		}
+		return
+	}
+
+	if len(newKey) != 32 {
+		newKey = nil
+		err = &errortypes.ParseError{
+			errors.New("secret: Invalid key length"),
+		}
+		return
+	}
+
+	return
+}
+
+// createKey generates the machine key, the key is sealed with the TPM
+// when available otherwise stored in a file only readable by root
+func createKey(pth string) (newKey []byte, err error) {
+	newKey, err = utils.RandBytes(32)
+	if err != nil {
+		return
+	}
+
+	keyDt := &keyData{
+		Type: KeyFile,
+		Data: base64.StdEncoding.EncodeToString(newKey),
+	}
+
+	if tpm.SealAvailable() {
+		sealed, e := tpm.Seal(newKey)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": e,
+			}).Error("secret: Failed to seal key with TPM")
+		} else {
+			keyDt.Type = KeyTpm
+			keyDt.Data = base64.StdEncoding.EncodeToString(sealed)
+		}
+	}
+
+	if keyDt.Type == KeyFile {
+		logrus.WithFields(logrus.Fields{
+			"path": pth,
+		}).Error("secret: TPM unavailable, machine key is stored " +
+			"unsealed and only protected by file permissions")
+	}
+
+	data, err := json.Marshal(keyDt)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "secret: Failed to marshal key file"),
+		}
+		return
+	}
+
+	err = platform.MkdirSecure(filepath.Dir(pth))
+	if err != nil {
+		return
+	}
+
+	err = utils.CreateWrite(pth, string(data), 0600)
+	if err != nil {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"type": keyDt.Type,
+	}).Info("secret: Created machine key")
+
+	return
+}
+
+func getKey() (curKey []byte, err error) {
+	keyLock.Lock()
+	defer keyLock.Unlock()
+
+	if key != nil {
+		curKey = key
+		return
+	}
+
+	pth := GetKeyPath()
+
+	exists, err := utils.ExistsFile(pth)
+	if err != nil {
+		return
+	}
+
+	if !exists {
+		err = migrateKey(pth)
+		if err != nil {
+			return
+		}
+
+		exists, err = utils.ExistsFile(pth)
+		if err != nil {
+			return
+		}
+	}
+
+	if exists {
+		curKey, err = loadKey(pth)
+	} else {
+		curKey, err = createKey(pth)
+	}
+	if err != nil {
+		return
+	}
+
+	key = curKey
+	return
+}
+
+// Init loads or creates the machine key, must be called before any
+// secrets are loaded
+func Init() (err error) {
+	_, err = getKey()
+	if err != nil {
+		return
+	}
+
+	return
+}
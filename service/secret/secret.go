@@ -0,0 +1,110 @@
+// Encryption of secrets stored on disk using a key sealed to the machine.
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+const prefix = "$enc1$"
+
+func getCipher() (gcm cipher.AEAD, err error) {
+	curKey, err := getKey()
+	if err != nil {
+		return
+	}
+
+	block, err := aes.NewCipher(curKey)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "secret: Failed to load cipher"),
+		}
+		return
+	}
+
+	gcm, err = cipher.NewGCM(block)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "secret: Failed to load gcm"),
+		}
+		return
+	}
+
+	return
+}
+
+func IsEncrypted(val string) bool {
+	return strings.HasPrefix(val, prefix)
+}
+
+// Encrypt encrypts the value with the machine key, empty and already
+// encrypted values are returned unchanged
+func Encrypt(val string) (encVal string, err error) {
+	if val == "" || IsEncrypted(val) {
+		encVal = val
+		return
+	}
+
+	gcm, err := getCipher()
+	if err != nil {
+		return
+	}
+
+	nonce, err := utils.RandBytes(gcm.NonceSize())
+	if err != nil {
+		return
+	}
+
+	data := gcm.Seal(nonce, nonce, []byte(val), nil)
+	encVal = prefix + base64.RawStdEncoding.EncodeToString(data)
+
+	return
+}
+
+// Decrypt decrypts a value from Encrypt, values without the encryption
+// prefix are returned unchanged to support unencrypted files
+func Decrypt(val string) (decVal string, err error) {
+	if !IsEncrypted(val) {
+		decVal = val
+		return
+	}
+
+	data, err := base64.RawStdEncoding.DecodeString(
+		strings.TrimPrefix(val, prefix))
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "secret: Failed to decode value"),
+		}
+		return
+	}
+
+	gcm, err := getCipher()
+	if err != nil {
+		return
+	}
+
+	nonceSize := gcm.NonceSize()
+	if len(data) < nonceSize {
+		err = &errortypes.ParseError{
+			errors.New("secret: Encrypted value too short"),
+		}
+		return
+	}
+
+	decData, err := gcm.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "secret: Failed to decrypt value"),
+		}
+		return
+	}
+
+	decVal = string(decData)
+	return
+}
@@ -0,0 +1,96 @@
+package splittun
+
+import (
+	"sync"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+const (
+	Include = "include"
+	Exclude = "exclude"
+
+	maxSlots  = 16
+	markBase  = 0x7a00
+	tableBase = 7100
+	prioBase  = 100
+)
+
+var (
+	lock  = sync.Mutex{}
+	slots = map[string]int{}
+)
+
+type Config struct {
+	Id      string
+	Mode    string
+	Iface   string
+	Cgroups []string
+}
+
+func acquireSlot(prflId string) int {
+	if slot, ok := slots[prflId]; ok {
+		return slot
+	}
+
+	used := map[int]bool{}
+	for _, slot := range slots {
+		used[slot] = true
+	}
+
+	for i := 0; i < maxSlots; i++ {
+		if !used[i] {
+			slots[prflId] = i
+			return i
+		}
+	}
+
+	return -1
+}
+
+func Start(conf *Config) (err error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	slot := acquireSlot(conf.Id)
+	if slot < 0 {
+		err = &errortypes.WriteError{
+			errors.New("splittun: No available split tunnel slots"),
+		}
+		return
+	}
+
+	err = start(slot, conf)
+	if err != nil {
+		stop(slot)
+		delete(slots, conf.Id)
+		return
+	}
+
+	return
+}
+
+func Stop(prflId string) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	slot, ok := slots[prflId]
+	if !ok {
+		return
+	}
+	delete(slots, prflId)
+
+	stop(slot)
+}
+
+func Clean() {
+	lock.Lock()
+	defer lock.Unlock()
+
+	slots = map[string]int{}
+
+	for i := 0; i < maxSlots; i++ {
+		stop(i)
+	}
+}
@@ -0,0 +1,255 @@
+package splittun
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const cgroupRoot = "/sys/fs/cgroup"
+
+func Supported() bool {
+	exists, _ := utils.Exists(filepath.Join(cgroupRoot, "cgroup.controllers"))
+	return exists
+}
+
+func nftTable(slot int) string {
+	return fmt.Sprintf("pritunl_splittun%d", slot)
+}
+
+func getDefaultRoute(ip6 bool) (via, dev string) {
+	args := []string{"route", "show", "default", "table", "main"}
+	if ip6 {
+		args = append([]string{"-6"}, args...)
+	}
+
+	output, err := utils.ExecOutput("ip", args...)
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != "default" {
+			continue
+		}
+
+		for i := 1; i < len(fields)-1; i++ {
+			switch fields[i] {
+			case "via":
+				via = fields[i+1]
+			case "dev":
+				dev = fields[i+1]
+			}
+		}
+
+		if dev != "" {
+			return
+		}
+	}
+
+	return
+}
+
+func applyNft(slot int, conf *Config) (err error) {
+	mark := markBase + slot
+	table := nftTable(slot)
+
+	rules := &strings.Builder{}
+	rules.WriteString(fmt.Sprintf("table inet %s\n", table))
+	rules.WriteString(fmt.Sprintf("delete table inet %s\n", table))
+	rules.WriteString(fmt.Sprintf("table inet %s {\n", table))
+
+	rules.WriteString("\tchain output {\n")
+	rules.WriteString(
+		"\t\ttype route hook output priority mangle; policy accept;\n")
+	for _, cgroup := range conf.Cgroups {
+		cgroup = strings.Trim(cgroup, "/")
+		if cgroup == "" {
+			continue
+		}
+
+		exists, _ := utils.ExistsDir(filepath.Join(cgroupRoot, cgroup))
+		if !exists {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": conf.Id,
+				"cgroup":     cgroup,
+			}).Warn("splittun: Skipping missing cgroup")
+			continue
+		}
+
+		rules.WriteString(fmt.Sprintf(
+			"\t\tsocket cgroupv2 level %d \"%s\" meta mark set 0x%x\n",
+			len(strings.Split(cgroup, "/")), cgroup, mark,
+		))
+	}
+	rules.WriteString(fmt.Sprintf(
+		"\t\tmeta mark 0x%x ct mark set meta mark\n", mark))
+	rules.WriteString("\t}\n")
+
+	rules.WriteString("\tchain prerouting {\n")
+	rules.WriteString(
+		"\t\ttype filter hook prerouting priority mangle; policy accept;\n")
+	rules.WriteString(fmt.Sprintf(
+		"\t\tct mark 0x%x meta mark set ct mark\n", mark))
+	rules.WriteString("\t}\n")
+
+	rules.WriteString("\tchain postrouting {\n")
+	rules.WriteString(
+		"\t\ttype nat hook postrouting priority srcnat; policy accept;\n")
+	if conf.Mode == Include {
+		rules.WriteString(fmt.Sprintf(
+			"\t\tmeta mark 0x%x oifname \"%s\" masquerade\n",
+			mark, conf.Iface))
+	} else {
+		rules.WriteString(fmt.Sprintf(
+			"\t\tmeta mark 0x%x oifname != \"%s\" masquerade\n",
+			mark, conf.Iface))
+	}
+	rules.WriteString("\t}\n")
+
+	rules.WriteString("}\n")
+
+	output, err := utils.ExecInputOutputCombindLogged(
+		rules.String(), "nft", "-f", "-")
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": conf.Id,
+			"output":     output,
+			"error":      err,
+		}).Error("splittun: Failed to apply nftables rules")
+		return
+	}
+
+	return
+}
+
+func applyRoutes(slot int, conf *Config, ip6 bool) (err error) {
+	mark := fmt.Sprintf("0x%x", markBase+slot)
+	table := fmt.Sprintf("%d", tableBase+slot)
+	prio := fmt.Sprintf("%d", prioBase+slot)
+
+	family := "-4"
+	if ip6 {
+		family = "-6"
+	}
+
+	var routeArgs []string
+	if conf.Mode == Include {
+		routeArgs = []string{
+			family, "route", "replace", "default",
+			"dev", conf.Iface, "table", table,
+		}
+	} else {
+		via, dev := getDefaultRoute(ip6)
+		if dev == "" || dev == conf.Iface {
+			if !ip6 {
+				err = &errortypes.ReadError{
+					errors.New("splittun: Failed to find default route"),
+				}
+			}
+			return
+		}
+
+		routeArgs = []string{family, "route", "replace", "default"}
+		if via != "" {
+			routeArgs = append(routeArgs, "via", via)
+		}
+		routeArgs = append(routeArgs, "dev", dev, "table", table)
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(nil, "ip", routeArgs...)
+	if err != nil {
+		if ip6 {
+			err = nil
+		}
+		return
+	}
+
+	_, _ = utils.ExecCombinedOutput(
+		"ip", family, "rule", "del", "fwmark", mark,
+		"table", table, "priority", prio,
+	)
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", family, "rule", "add", "fwmark", mark,
+		"table", table, "priority", prio,
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func start(slot int, conf *Config) (err error) {
+	if conf.Iface == "" {
+		err = &errortypes.ReadError{
+			errors.New("splittun: Missing tunnel interface"),
+		}
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": conf.Id,
+		"mode":       conf.Mode,
+		"iface":      conf.Iface,
+		"cgroups":    conf.Cgroups,
+	}).Info("splittun: Starting split tunnel")
+
+	err = ioutil.WriteFile(
+		"/proc/sys/net/ipv4/conf/all/src_valid_mark", []byte("1"), 0644)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "splittun: Failed to set src_valid_mark"),
+		}
+		return
+	}
+
+	err = applyRoutes(slot, conf, false)
+	if err != nil {
+		return
+	}
+
+	err = applyRoutes(slot, conf, true)
+	if err != nil {
+		return
+	}
+
+	err = applyNft(slot, conf)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func stop(slot int) {
+	mark := fmt.Sprintf("0x%x", markBase+slot)
+	table := fmt.Sprintf("%d", tableBase+slot)
+	prio := fmt.Sprintf("%d", prioBase+slot)
+
+	_, _ = utils.ExecCombinedOutput(
+		"nft", "delete", "table", "inet", nftTable(slot))
+
+	for _, family := range []string{"-4", "-6"} {
+		for i := 0; i < 10; i++ {
+			_, e := utils.ExecCombinedOutput(
+				"ip", family, "rule", "del", "fwmark", mark,
+				"table", table, "priority", prio,
+			)
+			if e != nil {
+				break
+			}
+		}
+
+		_, _ = utils.ExecCombinedOutput(
+			"ip", family, "route", "flush", "table", table)
+	}
+}
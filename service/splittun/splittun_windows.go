@@ -0,0 +1,12 @@
+package splittun
+
+func Supported() bool {
+	return false
+}
+
+func start(slot int, conf *Config) (err error) {
+	return
+}
+
+func stop(slot int) {
+}
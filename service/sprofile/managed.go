@@ -0,0 +1,82 @@
+package sprofile
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+// Parse loads a profile from the conf data header and ovpn data
+func Parse(prflId, data string) (prfl *Sprofile, err error) {
+	prfl = &Sprofile{}
+
+	jsonData := ""
+	jsonFound := false
+	jsonLoaded := false
+
+	dataLines := strings.Split(data, "\n")
+	data = ""
+	for _, line := range dataLines {
+		if !jsonLoaded && !jsonFound && line == "#{" {
+			jsonFound = true
+			jsonLoaded = true
+		}
+
+		if jsonFound && strings.HasPrefix(line, "#") {
+			if line == "#}" {
+				jsonFound = false
+			}
+			jsonData += strings.Replace(line, "#", "", 1)
+		} else {
+			data += line + "\n"
+		}
+	}
+
+	if !jsonLoaded {
+		err = &errortypes.ParseError{
+			errors.New("sprofile: Conf data missing"),
+		}
+		return
+	}
+
+	err = json.Unmarshal([]byte(jsonData), prfl)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "sprofile: Failed to parse conf data"),
+		}
+		return
+	}
+
+	prfl.Id = prflId
+	prfl.OvpnData = data
+
+	return
+}
+
+// ApplyManaged overwrites the fields pinned by the managed configuration
+func (s *Sprofile) ApplyManaged() (err error) {
+	if !s.Managed || s.ManagedData == "" {
+		return
+	}
+
+	prflId := s.Id
+	managedHash := s.ManagedHash
+	managedData := s.ManagedData
+
+	err = json.Unmarshal([]byte(s.ManagedData), s)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "sprofile: Failed to parse managed data"),
+		}
+		return
+	}
+
+	s.Id = prflId
+	s.Managed = true
+	s.ManagedHash = managedHash
+	s.ManagedData = managedData
+
+	return
+}
@@ -0,0 +1,149 @@
+package sprofile
+
+import (
+	"net"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+const (
+	RuleConnect    = "connect"
+	RuleDisconnect = "disconnect"
+	RuleOn         = "on"
+	RuleNotOn      = "not_on"
+)
+
+type Network struct {
+	Ssids       []string
+	Addresses   []net.IP
+	DnsSuffixes []string
+}
+
+type Rule struct {
+	Name        string   `json:"name"`
+	Action      string   `json:"action"`
+	Condition   string   `json:"condition"`
+	Ssids       []string `json:"ssids"`
+	Subnets     []string `json:"subnets"`
+	DnsSuffixes []string `json:"dns_suffixes"`
+}
+
+type Rules []*Rule
+
+func normalizeSuffix(suffix string) string {
+	return strings.Trim(strings.ToLower(strings.TrimSpace(suffix)), ".")
+}
+
+func (r *Rule) empty() bool {
+	return len(r.Ssids) == 0 && len(r.Subnets) == 0 &&
+		len(r.DnsSuffixes) == 0
+}
+
+// On returns true if the network matches any ssid, subnet or dns suffix
+func (r *Rule) On(ntwk *Network) bool {
+	for _, ssid := range r.Ssids {
+		for _, ntwkSsid := range ntwk.Ssids {
+			if ssid == ntwkSsid {
+				return true
+			}
+		}
+	}
+
+	for _, subnet := range r.Subnets {
+		_, cidr, err := net.ParseCIDR(strings.TrimSpace(subnet))
+		if err != nil {
+			continue
+		}
+
+		for _, addr := range ntwk.Addresses {
+			if cidr.Contains(addr) {
+				return true
+			}
+		}
+	}
+
+	for _, suffix := range r.DnsSuffixes {
+		suffix = normalizeSuffix(suffix)
+		if suffix == "" {
+			continue
+		}
+
+		for _, ntwkSuffix := range ntwk.DnsSuffixes {
+			ntwkSuffix = normalizeSuffix(ntwkSuffix)
+			if ntwkSuffix == suffix ||
+				strings.HasSuffix(ntwkSuffix, "."+suffix) {
+
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Validate checks the rule actions, conditions and subnets, an empty
+// condition defaults to on
+func (r Rules) Validate() (err error) {
+	for _, rule := range r {
+		if rule == nil {
+			err = &errortypes.ParseError{
+				errors.New("sprofile: Empty network rule"),
+			}
+			return
+		}
+
+		if rule.Action != RuleConnect && rule.Action != RuleDisconnect {
+			err = &errortypes.ParseError{
+				errors.Newf("sprofile: Invalid network rule action '%s'",
+					rule.Action),
+			}
+			return
+		}
+
+		if rule.Condition == "" {
+			rule.Condition = RuleOn
+		}
+		if rule.Condition != RuleOn && rule.Condition != RuleNotOn {
+			err = &errortypes.ParseError{
+				errors.Newf(
+					"sprofile: Invalid network rule condition '%s'",
+					rule.Condition),
+			}
+			return
+		}
+
+		rule.Subnets, err = utils.FilterCidrs(rule.Subnets)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// Match returns the first rule with a condition satisfied by the network
+func (r Rules) Match(ntwk *Network) *Rule {
+	for _, rule := range r {
+		if rule == nil || rule.empty() {
+			continue
+		}
+
+		if rule.Action != RuleConnect && rule.Action != RuleDisconnect {
+			continue
+		}
+
+		on := rule.On(ntwk)
+		if rule.Condition == RuleNotOn {
+			on = !on
+		}
+
+		if on {
+			return rule
+		}
+	}
+
+	return nil
+}
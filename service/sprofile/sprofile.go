@@ -17,8 +17,11 @@ import (
 	"time"
 
 	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/credentials"
 	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/log"
 	"github.com/pritunl/pritunl-client-electron/service/platform"
+	"github.com/pritunl/pritunl-client-electron/service/secret"
 	"github.com/pritunl/pritunl-client-electron/service/utils"
 )
 
@@ -62,6 +65,14 @@ type Sprofile struct {
 	DisableGateway     bool     `json:"disable_gateway"`
 	DisableDns         bool     `json:"disable_dns"`
 	RestrictClient     bool     `json:"restrict_client"`
+	ProxyMode          bool     `json:"proxy_mode"`
+	WgUserspace        bool     `json:"wg_userspace"`
+	ProxyAddress       string   `json:"proxy_address"`
+	RouteIncludes      []string `json:"route_includes"`
+	RouteExcludes      []string `json:"route_excludes"`
+	SplitTunnelMode    string   `json:"split_tunnel_mode"`
+	SplitTunnelCgroups []string `json:"split_tunnel_cgroups"`
+	KillSwitch         bool     `json:"kill_switch"`
 	ForceDns           bool     `json:"force_dns"`
 	SsoAuth            bool     `json:"sso_auth"`
 	PasswordMode       string   `json:"password_mode"`
@@ -76,7 +87,11 @@ type Sprofile struct {
 	ServerPublicKey    []string `json:"server_public_key"`
 	ServerBoxPublicKey string   `json:"server_box_public_key"`
 	RegistrationKey    string   `json:"registration_key"`
+	NetworkRules       Rules    `json:"network_rules"`
 	OvpnData           string   `json:"ovpn_data"`
+	Managed            bool     `json:"managed"`
+	ManagedHash        string   `json:"managed_hash"`
+	ManagedData        string   `json:"managed_data"`
 	Path               string   `json:"-"`
 	Password           string   `json:"password"`
 	AuthErrorCount     int      `json:"-"`
@@ -102,6 +117,14 @@ type SprofileClient struct {
 	DisableGateway     bool     `json:"disable_Gateway"`
 	DisableDns         bool     `json:"disable_dns"`
 	RestrictClient     bool     `json:"restrict_client"`
+	ProxyMode          bool     `json:"proxy_mode"`
+	WgUserspace        bool     `json:"wg_userspace"`
+	ProxyAddress       string   `json:"proxy_address"`
+	RouteIncludes      []string `json:"route_includes"`
+	RouteExcludes      []string `json:"route_excludes"`
+	SplitTunnelMode    string   `json:"split_tunnel_mode"`
+	SplitTunnelCgroups []string `json:"split_tunnel_cgroups"`
+	KillSwitch         bool     `json:"kill_switch"`
 	ForceDns           bool     `json:"force_dns"`
 	SsoAuth            bool     `json:"sso_auth"`
 	PasswordMode       string   `json:"password_mode"`
@@ -116,6 +139,8 @@ type SprofileClient struct {
 	ServerPublicKey    []string `json:"server_public_key"`
 	ServerBoxPublicKey string   `json:"server_box_public_key"`
 	RegistrationKey    string   `json:"registration_key"`
+	Managed            bool     `json:"managed"`
+	NetworkRules       Rules    `json:"network_rules"`
 	OvpnData           string   `json:"ovpn_data"`
 }
 
@@ -145,6 +170,14 @@ func (s *Sprofile) Client() (sprflc *SprofileClient) {
 		DisableGateway:     s.DisableGateway,
 		DisableDns:         s.DisableDns,
 		RestrictClient:     s.RestrictClient,
+		ProxyMode:          s.ProxyMode,
+		WgUserspace:        s.WgUserspace,
+		ProxyAddress:       s.ProxyAddress,
+		RouteIncludes:      s.RouteIncludes,
+		RouteExcludes:      s.RouteExcludes,
+		SplitTunnelMode:    s.SplitTunnelMode,
+		SplitTunnelCgroups: s.SplitTunnelCgroups,
+		KillSwitch:         s.KillSwitch,
 		ForceDns:           s.ForceDns,
 		SsoAuth:            s.SsoAuth,
 		PasswordMode:       s.PasswordMode,
@@ -159,6 +192,8 @@ func (s *Sprofile) Client() (sprflc *SprofileClient) {
 		ServerPublicKey:    s.ServerPublicKey,
 		ServerBoxPublicKey: s.ServerBoxPublicKey,
 		RegistrationKey:    s.RegistrationKey,
+		Managed:            s.Managed,
+		NetworkRules:       s.NetworkRules,
 		OvpnData:           s.OvpnData,
 	}
 
@@ -182,6 +217,14 @@ func (s *Sprofile) Copy() (sprfl *Sprofile) {
 		}
 	}
 
+	var networkRules Rules
+	if s.NetworkRules != nil {
+		networkRules = Rules{}
+		for _, rule := range s.NetworkRules {
+			networkRules = append(networkRules, rule)
+		}
+	}
+
 	sprfl = &Sprofile{
 		Id:                 s.Id,
 		Name:               s.Name,
@@ -203,6 +246,14 @@ func (s *Sprofile) Copy() (sprfl *Sprofile) {
 		DisableGateway:     s.DisableGateway,
 		DisableDns:         s.DisableDns,
 		RestrictClient:     s.RestrictClient,
+		ProxyMode:          s.ProxyMode,
+		WgUserspace:        s.WgUserspace,
+		ProxyAddress:       s.ProxyAddress,
+		RouteIncludes:      s.RouteIncludes,
+		RouteExcludes:      s.RouteExcludes,
+		SplitTunnelMode:    s.SplitTunnelMode,
+		SplitTunnelCgroups: s.SplitTunnelCgroups,
+		KillSwitch:         s.KillSwitch,
 		ForceDns:           s.ForceDns,
 		SsoAuth:            s.SsoAuth,
 		PasswordMode:       s.PasswordMode,
@@ -217,7 +268,11 @@ func (s *Sprofile) Copy() (sprfl *Sprofile) {
 		ServerPublicKey:    serverPublicKey,
 		ServerBoxPublicKey: s.ServerBoxPublicKey,
 		RegistrationKey:    s.RegistrationKey,
+		NetworkRules:       networkRules,
 		OvpnData:           s.OvpnData,
+		Managed:            s.Managed,
+		ManagedHash:        s.ManagedHash,
+		ManagedData:        s.ManagedData,
 		Path:               s.Path,
 		Password:           s.Password,
 		AuthErrorCount:     s.AuthErrorCount,
@@ -428,6 +483,12 @@ func (s *Sprofile) syncUpdate(data string) (updated bool, err error) {
 	}
 
 	s.OvpnData = data + tlsAuth + tlsCrypt + cert + key
+
+	err = s.ApplyManaged()
+	if err != nil {
+		return
+	}
+
 	err = s.Commit()
 	if err != nil {
 		return
@@ -564,7 +625,84 @@ func (s *Sprofile) Sync() (updated bool, err error) {
 	return
 }
 
-func (s *Sprofile) Commit() (err error) {
+// encrypt returns a copy of the profile with the secret fields encrypted
+func (s *Sprofile) encrypt() (sprfl *Sprofile, err error) {
+	sprfl = s.Copy()
+
+	sprfl.OvpnData, err = secret.Encrypt(s.OvpnData)
+	if err != nil {
+		return
+	}
+
+	sprfl.SyncSecret, err = secret.Encrypt(s.SyncSecret)
+	if err != nil {
+		return
+	}
+
+	sprfl.SyncToken, err = secret.Encrypt(s.SyncToken)
+	if err != nil {
+		return
+	}
+
+	// Passwords are saved to the credential store
+	sprfl.Password = ""
+
+	return
+}
+
+// decrypt decrypts the secret fields, migrate is set when a secret field
+// was stored unencrypted and the profile should be written again
+func (s *Sprofile) decrypt() (migrate bool, err error) {
+	vals := []*string{
+		&s.OvpnData,
+		&s.SyncSecret,
+		&s.SyncToken,
+		&s.Password,
+	}
+
+	for _, val := range vals {
+		if *val != "" && !secret.IsEncrypted(*val) {
+			migrate = true
+		}
+
+		*val, err = secret.Decrypt(*val)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// loadPassword moves a password stored in the profile conf to the
+// credential store or loads the saved password
+func (s *Sprofile) loadPassword(curPrfl *Sprofile) (migrate bool,
+	err error) {
+
+	if s.Password != "" {
+		err = credentials.Set(s.Id, s.Password)
+		if err != nil {
+			return
+		}
+
+		migrate = true
+		return
+	}
+
+	if curPrfl != nil {
+		s.Password = curPrfl.Password
+		return
+	}
+
+	s.Password, err = credentials.Get(s.Id)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (s *Sprofile) write() (err error) {
 	prflsPath := GetPath()
 
 	err = platform.MkdirSecure(prflsPath)
@@ -574,7 +712,12 @@ func (s *Sprofile) Commit() (err error) {
 
 	pth := filepath.Join(prflsPath, s.Id+".conf")
 
-	data, err := json.Marshal(s)
+	encPrfl, err := s.encrypt()
+	if err != nil {
+		return
+	}
+
+	data, err := json.Marshal(encPrfl)
 	if err != nil {
 		err = &errortypes.ParseError{
 			errors.Wrap(err, "sprofiles: Failed to parse profile data"),
@@ -587,6 +730,15 @@ func (s *Sprofile) Commit() (err error) {
 		return
 	}
 
+	return
+}
+
+func (s *Sprofile) Commit() (err error) {
+	err = s.write()
+	if err != nil {
+		return
+	}
+
 	cacheStale = true
 
 	return
@@ -600,6 +752,8 @@ func (s *Sprofile) Delete() (err error) {
 	_ = utils.Remove(prflPth)
 	_ = utils.Remove(logPth1)
 	_ = utils.Remove(logPth2)
+	_ = credentials.Remove(s.Id)
+	_ = log.ClearProfileHistory(s.Id)
 
 	return
 }
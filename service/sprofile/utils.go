@@ -11,7 +11,9 @@ import (
 	"sync"
 
 	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/credentials"
 	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/log"
 	"github.com/pritunl/pritunl-client-electron/service/utils"
 	"github.com/sirupsen/logrus"
 )
@@ -20,8 +22,21 @@ var (
 	cache      = []*Sprofile{}
 	cacheStale = true
 	cacheLock  = sync.Mutex{}
+	changes    = make(chan string, 64)
 )
 
+// Changes receives the ID of profiles activated or deactivated
+func Changes() <-chan string {
+	return changes
+}
+
+func notifyChange(prflId string) {
+	select {
+	case changes <- prflId:
+	default:
+	}
+}
+
 func Activate(prflId, mode, password string) (err error) {
 	cacheLock.Lock()
 	defer cacheLock.Unlock()
@@ -36,6 +51,11 @@ func Activate(prflId, mode, password string) (err error) {
 			prfl.LastMode = mode
 			prfl.Password = password
 
+			err = credentials.Set(prfl.Id, password)
+			if err != nil {
+				return
+			}
+
 			err = prfl.Commit()
 			if err != nil {
 				return
@@ -45,6 +65,7 @@ func Activate(prflId, mode, password string) (err error) {
 	}
 
 	cache = prflsCache
+	notifyChange(prflId)
 
 	return
 }
@@ -64,6 +85,7 @@ func Deactivate(prflId string) {
 	}
 
 	cache = prflsCache
+	notifyChange(prflId)
 }
 
 func SetAuthErrorCount(prflId string, errorCount int) {
@@ -156,6 +178,8 @@ func Remove(prflId string) {
 
 	_ = os.Remove(prflPth)
 	_ = os.Remove(logPth)
+	_ = credentials.Remove(prflId)
+	_ = log.ClearProfileHistory(prflId)
 
 	cacheStale = true
 }
@@ -218,6 +242,36 @@ func Reload(init bool) (err error) {
 			continue
 		}
 
+		migrate, e := prfl.decrypt()
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"path":  pth,
+				"error": e,
+			}).Error("sprofile: Failed to decrypt profile configuration")
+			continue
+		}
+
+		passMigrate, e := prfl.loadPassword(curPrfls[prfl.Id])
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"path":  pth,
+				"error": e,
+			}).Error("sprofile: Failed to load profile password")
+			migrate = false
+		} else if passMigrate {
+			migrate = true
+		}
+
+		if migrate {
+			e = prfl.write()
+			if e != nil {
+				logrus.WithFields(logrus.Fields{
+					"path":  pth,
+					"error": e,
+				}).Error("sprofile: Failed to encrypt profile configuration")
+			}
+		}
+
 		if init {
 			prfl.State = !prfl.Disabled
 		} else {
@@ -236,6 +290,133 @@ func Reload(init bool) (err error) {
 	return
 }
 
+// ReloadFile reloads a single profile configuration, a removed file is
+// removed from the cache. An invalid file leaves the cached profile.
+func ReloadFile(pth string) (prflId string, err error) {
+	name := filepath.Base(pth)
+	if !strings.HasSuffix(name, ".conf") {
+		return
+	}
+	prflId = strings.TrimSuffix(name, ".conf")
+
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+
+	data, err := ioutil.ReadFile(pth)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+
+			prflsCache := []*Sprofile{}
+			for _, prfl := range cache {
+				if prfl.Id != prflId {
+					prflsCache = append(prflsCache, prfl)
+				}
+			}
+			cache = prflsCache
+
+			return
+		}
+
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "sprofile: Failed to read profile configuration"),
+		}
+		return
+	}
+
+	prfl := &Sprofile{
+		Path: pth,
+	}
+
+	err = json.Unmarshal(data, prfl)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "sprofile: Failed to parse conf"),
+		}
+		return
+	}
+
+	if prfl.Id == "" || prfl.Id != prflId ||
+		utils.FilterStr(prfl.Id) != prfl.Id {
+
+		err = &errortypes.ParseError{
+			errors.New("sprofile: Profile ID does not match conf name"),
+		}
+		return
+	}
+
+	migrate, err := prfl.decrypt()
+	if err != nil {
+		return
+	}
+
+	var cachePrfl *Sprofile
+	for _, curPrfl := range cache {
+		if curPrfl.Id == prflId {
+			cachePrfl = curPrfl
+		}
+	}
+
+	passMigrate, e := prfl.loadPassword(cachePrfl)
+	if e != nil {
+		logrus.WithFields(logrus.Fields{
+			"path":  pth,
+			"error": e,
+		}).Error("sprofile: Failed to load profile password")
+		migrate = false
+	} else if passMigrate {
+		migrate = true
+	}
+
+	if migrate {
+		e := prfl.write()
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"path":  pth,
+				"error": e,
+			}).Error("sprofile: Failed to encrypt profile configuration")
+		}
+	}
+
+	found := false
+	prflsCache := []*Sprofile{}
+	for _, curPrfl := range cache {
+		if curPrfl.Id == prflId {
+			prfl.ImportState(curPrfl)
+			curPrfl = prfl
+			found = true
+		}
+		prflsCache = append(prflsCache, curPrfl)
+	}
+	if !found {
+		prfl.State = !prfl.Disabled
+		prflsCache = append(prflsCache, prfl)
+	}
+
+	cache = prflsCache
+
+	return
+}
+
+// ClearCredentials removes the saved password for the profile
+func ClearCredentials(prflId string) (err error) {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+
+	err = credentials.Remove(prflId)
+	if err != nil {
+		return
+	}
+
+	for _, prfl := range cache {
+		if prfl.Id == prflId {
+			prfl.Password = ""
+		}
+	}
+
+	return
+}
+
 func ClearLog(prflId string) (err error) {
 	prflsPath := GetPath()
 	pth := filepath.Join(prflsPath, fmt.Sprintf("%s.log", prflId))
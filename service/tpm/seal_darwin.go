@@ -0,0 +1,24 @@
+package tpm
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+func SealAvailable() bool {
+	return false
+}
+
+func Seal(data []byte) (sealed []byte, err error) {
+	err = &errortypes.UnknownError{
+		errors.New("tpm: Sealing not supported on this platform"),
+	}
+	return
+}
+
+func Unseal(sealed []byte) (data []byte, err error) {
+	err = &errortypes.UnknownError{
+		errors.New("tpm: Sealing not supported on this platform"),
+	}
+	return
+}
@@ -0,0 +1,99 @@
+package tpm
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/google/go-tpm-tools/client"
+	pb "github.com/google/go-tpm-tools/proto/tpm"
+	"github.com/google/go-tpm/legacy/tpm2"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"google.golang.org/protobuf/proto"
+)
+
+func SealAvailable() bool {
+	tpmDev, err := tpm2.OpenTPM()
+	if err != nil {
+		return false
+	}
+	_ = tpmDev.Close()
+
+	return true
+}
+
+// Seal encrypts data with the TPM storage root key, the sealed data can
+// only be unsealed by the same TPM
+func Seal(data []byte) (sealed []byte, err error) {
+	tpmDev, err := tpm2.OpenTPM()
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "tpm: Failed to open tpm"),
+		}
+		return
+	}
+	defer tpmDev.Close()
+
+	srk, err := client.StorageRootKeyECC(tpmDev)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "tpm: Failed to load storage root key"),
+		}
+		return
+	}
+	defer srk.Close()
+
+	sealedData, err := srk.Seal(data, client.SealOpts{})
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "tpm: Failed to seal data"),
+		}
+		return
+	}
+
+	sealed, err = proto.Marshal(sealedData)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "tpm: Failed to marshal sealed data"),
+		}
+		return
+	}
+
+	return
+}
+
+func Unseal(sealed []byte) (data []byte, err error) {
+	sealedData := &pb.SealedBytes{}
+	err = proto.Unmarshal(sealed, sealedData)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "tpm: Failed to unmarshal sealed data"),
+		}
+		return
+	}
+
+	tpmDev, err := tpm2.OpenTPM()
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "tpm: Failed to open tpm"),
+		}
+		return
+	}
+	defer tpmDev.Close()
+
+	srk, err := client.StorageRootKeyECC(tpmDev)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "tpm: Failed to load storage root key"),
+		}
+		return
+	}
+	defer srk.Close()
+
+	data, err = srk.Unseal(sealedData, client.UnsealOpts{})
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "tpm: Failed to unseal data"),
+		}
+		return
+	}
+
+	return
+}
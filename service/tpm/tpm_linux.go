@@ -99,34 +99,29 @@ func (t *Tpm) Sign(data []byte) (privKey64, sig64 string, err error) {
 	return
 }
 
-func getTpmPath() (pth string, err error) {
-	pth = "/dev/tpmrm0"
-	exists, err := utils.Exists(pth)
-	if err != nil || exists {
-		return
-	}
-
-	pth = "/dev/tpm0"
-	exists, err = utils.Exists(pth)
-	if err != nil || exists {
-		return
-	}
+var tpmPaths = []string{
+	"/dev/tpmrm0",
+	"/dev/tpm0",
+	"/dev/tpmrm1",
+	"/dev/tpm1",
+	"/dev/tpm",
+}
 
-	pth = "/dev/tpmrm1"
-	exists, err = utils.Exists(pth)
-	if err != nil || exists {
-		return
+func findTpmPath() (pth string) {
+	for _, tpmPth := range tpmPaths {
+		exists, _ := utils.Exists(tpmPth)
+		if exists {
+			pth = tpmPth
+			return
+		}
 	}
 
-	pth = "/dev/tpm1"
-	exists, err = utils.Exists(pth)
-	if err != nil || exists {
-		return
-	}
+	return
+}
 
-	pth = "/dev/tpm"
-	exists, err = utils.Exists(pth)
-	if err != nil || exists {
+func getTpmPath() (pth string, err error) {
+	pth = findTpmPath()
+	if pth != "" {
 		return
 	}
 
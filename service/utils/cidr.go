@@ -0,0 +1,197 @@
+package utils
+
+import (
+	"net/netip"
+	"sort"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+func ParseCidrs(cidrs []string) (prefixes []netip.Prefix, err error) {
+	prefixes = []netip.Prefix{}
+
+	for _, cidr := range cidrs {
+		prefix, e := netip.ParsePrefix(cidr)
+		if e != nil {
+			addr, e2 := netip.ParseAddr(cidr)
+			if e2 != nil {
+				err = &errortypes.ParseError{
+					errors.Wrapf(e, "utils: Invalid network '%s'", cidr),
+				}
+				return
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return
+}
+
+func FormatCidrs(prefixes []netip.Prefix) (cidrs []string) {
+	cidrs = []string{}
+
+	for _, prefix := range prefixes {
+		cidrs = append(cidrs, prefix.String())
+	}
+
+	return
+}
+
+func FilterCidrs(cidrs []string) (filtered []string, err error) {
+	prefixes, err := ParseCidrs(cidrs)
+	if err != nil {
+		return
+	}
+
+	filtered = FormatCidrs(prefixes)
+
+	return
+}
+
+func splitPrefix(prefix netip.Prefix) (lower, upper netip.Prefix) {
+	bits := prefix.Bits() + 1
+	lower = netip.PrefixFrom(prefix.Addr(), bits)
+
+	addr := prefix.Addr().AsSlice()
+	index := prefix.Bits() / 8
+	addr[index] |= 0x80 >> (prefix.Bits() % 8)
+
+	upperAddr, _ := netip.AddrFromSlice(addr)
+	if prefix.Addr().Is4() {
+		upperAddr = upperAddr.Unmap()
+	}
+	upper = netip.PrefixFrom(upperAddr, bits)
+
+	return
+}
+
+func prefixContains(outer, inner netip.Prefix) bool {
+	return outer.Bits() <= inner.Bits() && outer.Contains(inner.Addr())
+}
+
+func subtractPrefix(prefix, exclude netip.Prefix) (result []netip.Prefix) {
+	if prefix.Addr().Is4() != exclude.Addr().Is4() ||
+		!prefix.Overlaps(exclude) {
+
+		result = []netip.Prefix{prefix}
+		return
+	}
+
+	if prefixContains(exclude, prefix) {
+		result = []netip.Prefix{}
+		return
+	}
+
+	lower, upper := splitPrefix(prefix)
+	result = append(subtractPrefix(lower, exclude),
+		subtractPrefix(upper, exclude)...)
+
+	return
+}
+
+func comparePrefix(a, b netip.Prefix) int {
+	if a.Addr().Is4() != b.Addr().Is4() {
+		if a.Addr().Is4() {
+			return -1
+		}
+		return 1
+	}
+
+	cmp := a.Addr().Compare(b.Addr())
+	if cmp != 0 {
+		return cmp
+	}
+
+	if a.Bits() < b.Bits() {
+		return -1
+	} else if a.Bits() > b.Bits() {
+		return 1
+	}
+	return 0
+}
+
+// AggregatePrefixes returns the minimal set of prefixes covering the same
+// addresses by removing contained prefixes and merging sibling prefixes.
+func AggregatePrefixes(prefixes []netip.Prefix) (result []netip.Prefix) {
+	result = make([]netip.Prefix, len(prefixes))
+	copy(result, prefixes)
+
+	for {
+		sort.Slice(result, func(i, j int) bool {
+			return comparePrefix(result[i], result[j]) < 0
+		})
+
+		changed := false
+		merged := []netip.Prefix{}
+
+		for _, prefix := range result {
+			if len(merged) == 0 {
+				merged = append(merged, prefix)
+				continue
+			}
+
+			last := merged[len(merged)-1]
+			if last.Addr().Is4() == prefix.Addr().Is4() &&
+				prefixContains(last, prefix) {
+
+				changed = true
+				continue
+			}
+
+			if last.Bits() == prefix.Bits() && last.Bits() > 0 &&
+				last.Addr().Is4() == prefix.Addr().Is4() {
+
+				parent := netip.PrefixFrom(
+					last.Addr(), last.Bits()-1).Masked()
+				_, upper := splitPrefix(parent)
+				if parent.Addr() == last.Addr() && upper == prefix {
+					merged[len(merged)-1] = parent
+					changed = true
+					continue
+				}
+			}
+
+			merged = append(merged, prefix)
+		}
+
+		result = merged
+		if !changed {
+			break
+		}
+	}
+
+	return
+}
+
+// SubtractCidrs removes the excluded networks from the included networks
+// and returns the minimal set of prefixes covering the remainder.
+func SubtractCidrs(includes, excludes []string) (result []string,
+	err error) {
+
+	includePrefixes, err := ParseCidrs(includes)
+	if err != nil {
+		return
+	}
+
+	excludePrefixes, err := ParseCidrs(excludes)
+	if err != nil {
+		return
+	}
+
+	prefixes := includePrefixes
+	for _, exclude := range excludePrefixes {
+		newPrefixes := []netip.Prefix{}
+		for _, prefix := range prefixes {
+			newPrefixes = append(newPrefixes,
+				subtractPrefix(prefix, exclude)...)
+		}
+		prefixes = newPrefixes
+	}
+
+	result = FormatCidrs(AggregatePrefixes(prefixes))
+
+	return
+}
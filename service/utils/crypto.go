@@ -12,6 +12,7 @@ import (
 
 	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"golang.org/x/crypto/curve25519"
 )
 
 var (
@@ -99,6 +100,29 @@ func RandBytes(size int) (bytes []byte, err error) {
 	return
 }
 
+func GenerateWgKey() (privateKey, publicKey string, err error) {
+	privKey, err := RandBytes(curve25519.ScalarSize)
+	if err != nil {
+		return
+	}
+
+	privKey[0] &= 248
+	privKey[31] = (privKey[31] & 127) | 64
+
+	pubKey, err := curve25519.X25519(privKey, curve25519.Basepoint)
+	if err != nil {
+		err = &errortypes.UnknownError{
+			errors.Wrap(err, "utils: Failed to generate wg public key"),
+		}
+		return
+	}
+
+	privateKey = base64.StdEncoding.EncodeToString(privKey)
+	publicKey = base64.StdEncoding.EncodeToString(pubKey)
+
+	return
+}
+
 func init() {
 	n, err := rand.Int(rand.Reader, big.NewInt(9223372036854775806))
 	if err != nil {
@@ -0,0 +1,214 @@
+package watch
+
+import (
+	"net"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/connection"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/sirupsen/logrus"
+)
+
+// getNetwork collects the current physical network, addresses and search
+// domains belonging to active connections are excluded
+func getNetwork() (ntwk *sprofile.Network) {
+	tunIfaces := map[string]bool{}
+	tunDomains := map[string]bool{}
+
+	for _, conn := range connection.GlobalStore.GetAll() {
+		if conn.Data == nil {
+			continue
+		}
+
+		if conn.Data.Iface != "" {
+			tunIfaces[conn.Data.Iface] = true
+		}
+		if conn.Data.WgTunIface != "" {
+			tunIfaces[conn.Data.WgTunIface] = true
+		}
+		for _, domain := range conn.Data.SearchDomains {
+			tunDomains[strings.ToLower(strings.Trim(domain, "."))] = true
+		}
+	}
+
+	ntwk = &sprofile.Network{
+		Ssids:       networkSsids(),
+		Addresses:   []net.IP{},
+		DnsSuffixes: []string{},
+	}
+
+	ifaces, err := net.Interfaces()
+	if err == nil {
+		for _, iface := range ifaces {
+			if iface.Flags&net.FlagUp == 0 ||
+				iface.Flags&net.FlagLoopback != 0 ||
+				iface.Flags&net.FlagPointToPoint != 0 ||
+				tunIfaces[iface.Name] {
+
+				continue
+			}
+
+			addrs, e := iface.Addrs()
+			if e != nil {
+				continue
+			}
+
+			for _, addr := range addrs {
+				ipNet, ok := addr.(*net.IPNet)
+				if !ok || ipNet.IP.IsLinkLocalUnicast() {
+					continue
+				}
+
+				ntwk.Addresses = append(ntwk.Addresses, ipNet.IP)
+			}
+		}
+	}
+
+	for _, suffix := range networkDnsSuffixes() {
+		if tunDomains[strings.ToLower(strings.Trim(suffix, "."))] {
+			continue
+		}
+		ntwk.DnsSuffixes = append(ntwk.DnsSuffixes, suffix)
+	}
+
+	return
+}
+
+func networkKey(ntwk *sprofile.Network) string {
+	vals := []string{}
+
+	for _, ssid := range ntwk.Ssids {
+		vals = append(vals, "ssid:"+ssid)
+	}
+	for _, addr := range ntwk.Addresses {
+		// Ignore rotating ipv6 temporary addresses
+		if addr.To4() == nil {
+			continue
+		}
+		vals = append(vals, "addr:"+addr.String())
+	}
+	for _, suffix := range ntwk.DnsSuffixes {
+		vals = append(vals, "dns:"+suffix)
+	}
+
+	sort.Strings(vals)
+	return strings.Join(vals, ",")
+}
+
+func hasNetworkRules() bool {
+	sprfls, err := sprofile.GetAll()
+	if err != nil {
+		return false
+	}
+
+	for _, sprfl := range sprfls {
+		if len(sprfl.NetworkRules) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func evalNetworkRules(ntwk *sprofile.Network) {
+	sprfls, err := sprofile.GetAll()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("watch: Failed to get system profiles")
+		return
+	}
+
+	for _, sprfl := range sprfls {
+		rule := sprfl.NetworkRules.Match(ntwk)
+		if rule == nil {
+			continue
+		}
+
+		fields := logrus.Fields{
+			"profile_id":     sprfl.Id,
+			"rule_name":      rule.Name,
+			"rule_action":    rule.Action,
+			"rule_condition": rule.Condition,
+			"ssids":          ntwk.Ssids,
+			"dns_suffixes":   ntwk.DnsSuffixes,
+		}
+
+		switch rule.Action {
+		case sprofile.RuleConnect:
+			if sprfl.State {
+				continue
+			}
+
+			logrus.WithFields(fields).Info(
+				"watch: Network rule connecting profile")
+
+			err = sprofile.Activate(sprfl.Id, sprfl.LastMode, sprfl.Password)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"profile_id": sprfl.Id,
+					"error":      err,
+				}).Error("watch: Failed to activate profile")
+			}
+			break
+		case sprofile.RuleDisconnect:
+			if !sprfl.State {
+				continue
+			}
+
+			logrus.WithFields(fields).Info(
+				"watch: Network rule disconnecting profile")
+
+			connection.GlobalStore.SetStop(sprfl.Id)
+			sprofile.Deactivate(sprfl.Id)
+			break
+		}
+	}
+}
+
+func networkWatch() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"trace": string(debug.Stack()),
+				"panic": panc,
+			}).Error("watch: Network watch panic")
+			time.Sleep(10 * time.Second)
+			go networkWatch()
+		}
+	}()
+
+	curKey := ""
+
+	for {
+		time.Sleep(5 * time.Second)
+
+		if connection.Shutdown {
+			return
+		}
+
+		if !hasNetworkRules() {
+			curKey = ""
+			continue
+		}
+
+		ntwk := getNetwork()
+		key := networkKey(ntwk)
+		if key == curKey {
+			continue
+		}
+		curKey = key
+
+		logrus.WithFields(logrus.Fields{
+			"ssids":        ntwk.Ssids,
+			"addresses":    ntwk.Addresses,
+			"dns_suffixes": ntwk.DnsSuffixes,
+		}).Info("watch: Network changed")
+
+		evalNetworkRules(ntwk)
+	}
+}
@@ -0,0 +1,52 @@
+package watch
+
+import (
+	"net"
+	"strings"
+
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+func networkSsids() (ssids []string) {
+	ssids = []string{}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return
+	}
+
+	for _, iface := range ifaces {
+		if !strings.HasPrefix(iface.Name, "en") {
+			continue
+		}
+
+		output, e := utils.ExecOutput("/usr/sbin/networksetup",
+			"-getairportnetwork", iface.Name)
+		if e != nil {
+			continue
+		}
+
+		output = strings.TrimSpace(output)
+		if !strings.HasPrefix(output, "Current Wi-Fi Network: ") {
+			continue
+		}
+
+		ssid := strings.TrimPrefix(output, "Current Wi-Fi Network: ")
+		if ssid != "" {
+			ssids = append(ssids, ssid)
+		}
+	}
+
+	return
+}
+
+func networkDnsSuffixes() (suffixes []string) {
+	global, err := utils.GetScutilKey("State", "/Network/Global/DNS")
+	if err != nil {
+		suffixes = []string{}
+		return
+	}
+
+	suffixes, _ = parseDns(global)
+	return
+}
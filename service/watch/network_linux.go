@@ -0,0 +1,69 @@
+package watch
+
+import (
+	"io/ioutil"
+	"strings"
+
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+var resolvPaths = []string{
+	"/run/systemd/resolve/resolv.conf",
+	"/etc/resolv.conf",
+}
+
+func networkSsids() (ssids []string) {
+	ssids = []string{}
+
+	output, err := utils.ExecOutput("nmcli", "-t", "-f",
+		"active,ssid", "dev", "wifi", "list", "--rescan", "no")
+	if err == nil {
+		for _, line := range strings.Split(output, "\n") {
+			if !strings.HasPrefix(line, "yes:") {
+				continue
+			}
+
+			ssid := strings.ReplaceAll(line[4:], "\\:", ":")
+			if ssid != "" {
+				ssids = append(ssids, ssid)
+			}
+		}
+		return
+	}
+
+	output, err = utils.ExecOutput("iwgetid", "-r")
+	if err == nil {
+		ssid := strings.TrimSpace(output)
+		if ssid != "" {
+			ssids = append(ssids, ssid)
+		}
+	}
+
+	return
+}
+
+func networkDnsSuffixes() (suffixes []string) {
+	suffixes = []string{}
+
+	for _, pth := range resolvPaths {
+		data, err := ioutil.ReadFile(pth)
+		if err != nil {
+			continue
+		}
+
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				continue
+			}
+
+			if fields[0] == "search" || fields[0] == "domain" {
+				suffixes = append(suffixes, fields[1:]...)
+			}
+		}
+
+		return
+	}
+
+	return
+}
@@ -0,0 +1,79 @@
+package watch
+
+import (
+	"strings"
+	"unsafe"
+
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"golang.org/x/sys/windows"
+)
+
+func networkSsids() (ssids []string) {
+	ssids = []string{}
+
+	output, err := utils.ExecOutput("netsh.exe", "wlan",
+		"show", "interfaces")
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		lineSpl := strings.SplitN(line, ":", 2)
+		if len(lineSpl) != 2 ||
+			strings.TrimSpace(lineSpl[0]) != "SSID" {
+
+			continue
+		}
+
+		ssid := strings.TrimSpace(lineSpl[1])
+		if ssid != "" {
+			ssids = append(ssids, ssid)
+		}
+	}
+
+	return
+}
+
+func networkDnsSuffixes() (suffixes []string) {
+	suffixes = []string{}
+
+	size := uint32(15000)
+	var buf []byte
+	var err error
+
+	for i := 0; i < 3; i++ {
+		buf = make([]byte, size)
+		err = windows.GetAdaptersAddresses(
+			windows.AF_UNSPEC,
+			windows.GAA_FLAG_SKIP_UNICAST|windows.GAA_FLAG_SKIP_ANYCAST|
+				windows.GAA_FLAG_SKIP_MULTICAST|
+				windows.GAA_FLAG_SKIP_DNS_SERVER,
+			0,
+			(*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0])),
+			&size,
+		)
+		if err != windows.ERROR_BUFFER_OVERFLOW {
+			break
+		}
+	}
+	if err != nil {
+		return
+	}
+
+	adapter := (*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0]))
+	for ; adapter != nil; adapter = adapter.Next {
+		if adapter.OperStatus != windows.IfOperStatusUp ||
+			adapter.IfType == windows.IF_TYPE_SOFTWARE_LOOPBACK ||
+			adapter.DnsSuffix == nil {
+
+			continue
+		}
+
+		suffix := windows.UTF16PtrToString(adapter.DnsSuffix)
+		if suffix != "" {
+			suffixes = append(suffixes, suffix)
+		}
+	}
+
+	return
+}
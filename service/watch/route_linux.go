@@ -0,0 +1,292 @@
+package watch
+
+import (
+	"fmt"
+	"net"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nlenc"
+	"github.com/pritunl/pritunl-client-electron/service/connection"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	routeSettle = 2 * time.Second
+	routeGroups = unix.RTMGRP_LINK | unix.RTMGRP_IPV4_IFADDR |
+		unix.RTMGRP_IPV6_IFADDR | unix.RTMGRP_IPV4_ROUTE |
+		unix.RTMGRP_IPV6_ROUTE
+)
+
+type routeEntry struct {
+	iface   string
+	gateway string
+	metric  uint32
+}
+
+func (r *routeEntry) String() string {
+	addrs := []string{}
+
+	ifc, err := net.InterfaceByName(r.iface)
+	if err == nil {
+		ifcAddrs, _ := ifc.Addrs()
+		for _, addr := range ifcAddrs {
+			// Ignore rotating ipv6 temporary addresses
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || ipNet.IP.To4() == nil {
+				continue
+			}
+			addrs = append(addrs, ipNet.String())
+		}
+	}
+
+	return fmt.Sprintf("%s/%s/%s", r.iface, r.gateway,
+		strings.Join(addrs, ","))
+}
+
+// parseRoute returns the family and entry of a main table default route
+// message, nil is returned for all other routes
+func parseRoute(data []byte) (family uint8, entry *routeEntry) {
+	if len(data) < unix.SizeofRtMsg {
+		return
+	}
+
+	dstLen := data[1]
+	table := uint32(data[4])
+	flags := nlenc.Uint32(data[8:12])
+	if dstLen != 0 || data[7] != unix.RTN_UNICAST ||
+		flags&unix.RTNH_F_LINKDOWN != 0 {
+
+		return
+	}
+
+	ad, err := netlink.NewAttributeDecoder(data[unix.SizeofRtMsg:])
+	if err != nil {
+		return
+	}
+
+	index := uint32(0)
+	gateway := ""
+	metric := uint32(0)
+	for ad.Next() {
+		switch ad.Type() {
+		case unix.RTA_OIF:
+			index = ad.Uint32()
+		case unix.RTA_GATEWAY:
+			gateway = net.IP(ad.Bytes()).String()
+		case unix.RTA_PRIORITY:
+			metric = ad.Uint32()
+		case unix.RTA_TABLE:
+			table = ad.Uint32()
+		}
+	}
+	if ad.Err() != nil || table != unix.RT_TABLE_MAIN || index == 0 {
+		return
+	}
+
+	ifc, err := net.InterfaceByIndex(int(index))
+	if err != nil || ifc.Flags&net.FlagPointToPoint != 0 {
+		return
+	}
+
+	family = data[0]
+	entry = &routeEntry{
+		iface:   ifc.Name,
+		gateway: gateway,
+		metric:  metric,
+	}
+	return
+}
+
+// defaultRoute returns the interface, gateway and interface addresses of
+// the lowest metric physical ipv4 and ipv6 default routes, tunnel
+// interfaces are ignored
+func defaultRoute() (route string) {
+	conn, err := netlink.Dial(unix.NETLINK_ROUTE, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	msgs, err := conn.Execute(netlink.Message{
+		Header: netlink.Header{
+			Type:  unix.RTM_GETROUTE,
+			Flags: netlink.Request | netlink.Dump,
+		},
+		Data: make([]byte, unix.SizeofRtMsg),
+	})
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("watch: Failed to dump routes")
+		return
+	}
+
+	tunIfaces := map[string]bool{}
+	for _, conn := range connection.GlobalStore.GetAll() {
+		if conn.Data == nil {
+			continue
+		}
+
+		if conn.Data.Iface != "" {
+			tunIfaces[conn.Data.Iface] = true
+		}
+		if conn.Data.WgTunIface != "" {
+			tunIfaces[conn.Data.WgTunIface] = true
+		}
+	}
+
+	var route4 *routeEntry
+	var route6 *routeEntry
+	for _, msg := range msgs {
+		family, entry := parseRoute(msg.Data)
+		if entry == nil || tunIfaces[entry.iface] {
+			continue
+		}
+
+		switch family {
+		case unix.AF_INET:
+			if route4 == nil || entry.metric < route4.metric {
+				route4 = entry
+			}
+		case unix.AF_INET6:
+			if route6 == nil || entry.metric < route6.metric {
+				route6 = entry
+			}
+		}
+	}
+
+	routes := []string{}
+	if route4 != nil {
+		routes = append(routes, route4.String())
+	}
+	if route6 != nil {
+		routes = append(routes, route6.String())
+	}
+
+	route = strings.Join(routes, " ")
+	return
+}
+
+func restartConnected(route string) {
+	restartLock.Lock()
+	lastRestart = time.Now()
+	restartLock.Unlock()
+
+	for _, conn := range connection.GlobalStore.GetAll() {
+		if conn.Data == nil || conn.Data.Status != connection.Connected {
+			continue
+		}
+
+		logrus.WithFields(conn.Fields(logrus.Fields{
+			"default_route": route,
+		})).Warn("watch: Default route changed, restarting connection")
+
+		go func(conn *connection.Connection) {
+			defer func() {
+				panc := recover()
+				if panc != nil {
+					logrus.WithFields(logrus.Fields{
+						"trace": string(debug.Stack()),
+						"panic": panc,
+					}).Error("watch: Connection restart panic")
+				}
+			}()
+
+			conn.Restart()
+		}(conn)
+	}
+}
+
+func routeWatch() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"trace": string(debug.Stack()),
+				"panic": panc,
+			}).Error("watch: Route watch panic")
+			time.Sleep(10 * time.Second)
+			go routeWatch()
+		}
+	}()
+
+	conn, err := netlink.Dial(unix.NETLINK_ROUTE, &netlink.Config{
+		Groups: routeGroups,
+	})
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("watch: Failed to subscribe to route changes")
+		return
+	}
+	defer conn.Close()
+
+	changes := make(chan bool, 1)
+	go func() {
+		defer close(changes)
+
+		for {
+			_, e := conn.Receive()
+			if e != nil {
+				if connection.Shutdown {
+					return
+				}
+
+				logrus.WithFields(logrus.Fields{
+					"error": e,
+				}).Error("watch: Failed to receive route changes")
+				return
+			}
+
+			select {
+			case changes <- true:
+			default:
+			}
+		}
+	}()
+
+	curRoute := defaultRoute()
+
+	for {
+		_, ok := <-changes
+		if !ok {
+			time.Sleep(10 * time.Second)
+			go routeWatch()
+			return
+		}
+
+		// Wait for address and route updates from a link change to settle
+		time.Sleep(routeSettle)
+		select {
+		case <-changes:
+		default:
+		}
+
+		if connection.Shutdown {
+			return
+		}
+
+		route := defaultRoute()
+		if route == "" || route == curRoute {
+			continue
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"previous_route": curRoute,
+			"default_route":  route,
+		}).Info("watch: Default route changed")
+
+		prevRoute := curRoute
+		curRoute = route
+
+		if prevRoute == "" {
+			continue
+		}
+
+		restartConnected(route)
+	}
+}
@@ -0,0 +1,4 @@
+package watch
+
+func routeWatch() {
+}
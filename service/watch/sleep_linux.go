@@ -0,0 +1,144 @@
+package watch
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/pritunl/pritunl-client-electron/service/connection"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	logindDest    = "org.freedesktop.login1"
+	logindPath    = "/org/freedesktop/login1"
+	logindManager = "org.freedesktop.login1.Manager"
+)
+
+// sleepInhibit takes a delay lock so connections can be stopped before
+// the system sleeps, the lock is released by closing the descriptor
+func sleepInhibit(conn *dbus.Conn) (fd int) {
+	fd = -1
+
+	var lockFd dbus.UnixFD
+	err := conn.Object(logindDest, logindPath).Call(
+		logindManager+".Inhibit", 0,
+		"sleep",
+		"Pritunl Client",
+		"Disconnecting VPN connections",
+		"delay",
+	).Store(&lockFd)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Warn("watch: Failed to take logind sleep inhibitor")
+		return
+	}
+
+	fd = int(lockFd)
+	return
+}
+
+func sleepRelease(fd int) {
+	if fd >= 0 {
+		_ = unix.Close(fd)
+	}
+}
+
+func sleepWatch() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"trace": string(debug.Stack()),
+				"panic": panc,
+			}).Error("watch: Sleep watch panic")
+			time.Sleep(10 * time.Second)
+			go sleepWatch()
+		}
+	}()
+
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Warn("watch: Failed to connect to system bus, " +
+			"sleep watch disabled")
+		return
+	}
+	defer conn.Close()
+
+	err = conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(logindPath),
+		dbus.WithMatchInterface(logindManager),
+		dbus.WithMatchMember("PrepareForSleep"),
+	)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Warn("watch: Failed to subscribe to logind, " +
+			"sleep watch disabled")
+		return
+	}
+
+	signals := make(chan *dbus.Signal, 10)
+	conn.Signal(signals)
+
+	lockFd := sleepInhibit(conn)
+	defer func() {
+		sleepRelease(lockFd)
+	}()
+
+	var prfls []*connection.Profile
+
+	for sig := range signals {
+		if sig.Name != logindManager+".PrepareForSleep" ||
+			len(sig.Body) < 1 {
+
+			continue
+		}
+
+		start, ok := sig.Body[0].(bool)
+		if !ok {
+			continue
+		}
+
+		if start {
+			logrus.Info("watch: System sleeping, stopping connections")
+
+			prfls = connection.SuspendProfiles()
+
+			sleepRelease(lockFd)
+			lockFd = -1
+		} else {
+			logrus.Info("watch: System resumed, restarting connections")
+
+			restartLock.Lock()
+			lastRestart = time.Now()
+			restartLock.Unlock()
+
+			connection.ResumeProfiles(prfls)
+			prfls = nil
+
+			lockFd = sleepInhibit(conn)
+		}
+	}
+
+	if connection.Shutdown {
+		return
+	}
+
+	logrus.Error("watch: Lost system bus connection, restarting sleep watch")
+
+	// The resume signal may have been lost with the bus connection
+	if connection.IsSuspended() {
+		restartLock.Lock()
+		lastRestart = time.Now()
+		restartLock.Unlock()
+
+		connection.ResumeProfiles(prfls)
+	}
+	time.Sleep(10 * time.Second)
+	go sleepWatch()
+}
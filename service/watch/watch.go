@@ -302,6 +302,21 @@ func StartWatch() {
 	} else {
 		go wakeWatch()
 	}
+	if config.Config.DisableSleepWatch {
+		logrus.Info("watch: Sleep watch disabled")
+	} else if runtime.GOOS == "linux" {
+		go sleepWatch()
+	}
+	if config.Config.DisableRouteWatch {
+		logrus.Info("watch: Route watch disabled")
+	} else if runtime.GOOS == "linux" {
+		go routeWatch()
+	}
+	if config.Config.DisableNetworkWatch {
+		logrus.Info("watch: Network watch disabled")
+	} else {
+		go networkWatch()
+	}
 	if config.Config.DisableDnsWatch {
 		logrus.Info("watch: DNS watch disabled")
 	} else {
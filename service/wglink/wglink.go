@@ -0,0 +1,119 @@
+// Native WireGuard interface configuration without wireguard-tools.
+package wglink
+
+import (
+	"encoding/base64"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+const (
+	defaultMtu = 1420
+	keyLen     = 32
+)
+
+type Config struct {
+	Iface          string
+	PrivateKey     string
+	Mtu            int
+	Addresses      []string
+	PublicKey      string
+	Endpoint       string
+	AllowedIps     []string
+	BypassIps      []string
+	NoDefaultRoute bool
+	DnsServers     []string
+	SearchDomains  []string
+}
+
+type Peer struct {
+	PublicKey     string
+	LastHandshake int64
+	RxBytes       uint64
+	TxBytes       uint64
+}
+
+func parseKey(key string) (data []byte, err error) {
+	data, err = base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "wglink: Failed to decode key"),
+		}
+		return
+	}
+
+	if len(data) != keyLen {
+		err = &errortypes.ParseError{
+			errors.New("wglink: Invalid key length"),
+		}
+		return
+	}
+
+	return
+}
+
+func parseAddress(addr string) (prefix netip.Prefix, err error) {
+	addr = strings.TrimSpace(addr)
+
+	if !strings.Contains(addr, "/") {
+		ip, e := netip.ParseAddr(addr)
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "wglink: Failed to parse address"),
+			}
+			return
+		}
+
+		prefix = netip.PrefixFrom(ip, ip.BitLen())
+		return
+	}
+
+	prefix, err = netip.ParsePrefix(addr)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "wglink: Failed to parse address"),
+		}
+		return
+	}
+
+	return
+}
+
+func parseEndpoint(endpoint string) (addr netip.AddrPort, err error) {
+	host, portStr, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "wglink: Failed to parse endpoint"),
+		}
+		return
+	}
+
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "wglink: Failed to parse endpoint port"),
+		}
+		return
+	}
+
+	ip, e := netip.ParseAddr(host)
+	if e != nil {
+		ipAddr, e := net.ResolveIPAddr("ip", host)
+		if e != nil {
+			err = &errortypes.RequestError{
+				errors.Wrap(e, "wglink: Failed to resolve endpoint"),
+			}
+			return
+		}
+
+		ip, _ = netip.AddrFromSlice(ipAddr.IP)
+	}
+
+	addr = netip.AddrPortFrom(ip.Unmap(), uint16(port))
+	return
+}
@@ -0,0 +1,39 @@
+package wglink
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+func Supported() bool {
+	return false
+}
+
+func Up(conf *Config) (err error) {
+	err = &errortypes.ExecError{
+		errors.New("wglink: Native interface not supported"),
+	}
+	return
+}
+
+func Fwmark(iface string) (fwmark uint32, err error) {
+	return
+}
+
+func Configure(conf *Config) (err error) {
+	err = &errortypes.ExecError{
+		errors.New("wglink: Native interface not supported"),
+	}
+	return
+}
+
+func Down(iface string) (err error) {
+	return
+}
+
+func GetPeer(iface, publicKey string) (peer *Peer, err error) {
+	err = &errortypes.ExecError{
+		errors.New("wglink: Native interface not supported"),
+	}
+	return
+}
@@ -0,0 +1,893 @@
+package wglink
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"net/netip"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nlenc"
+	"github.com/pritunl/pritunl-client-electron/service/dns"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	familyName   = "wireguard"
+	tableBase    = 51820
+	suppressPrio = 31000
+	fwmarkPrio   = 31001
+	srcValidMark = "/proc/sys/net/ipv4/conf/all/src_valid_mark"
+)
+
+func Supported() bool {
+	conn, err := netlink.Dial(unix.NETLINK_GENERIC, nil)
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+
+	return true
+}
+
+func isErrno(err error, errno unix.Errno) bool {
+	opErr, ok := err.(*netlink.OpError)
+	return ok && opErr.Err == errno
+}
+
+func genlHeader(cmd uint8) []byte {
+	return []byte{cmd, unix.WG_GENL_VERSION, 0, 0}
+}
+
+func ifInfoMsg(index int32, flags, change uint32) []byte {
+	data := make([]byte, unix.SizeofIfInfomsg)
+	data[0] = unix.AF_UNSPEC
+	nlenc.PutInt32(data[4:8], index)
+	nlenc.PutUint32(data[8:12], flags)
+	nlenc.PutUint32(data[12:16], change)
+	return data
+}
+
+func addrFamily(addr netip.Addr) uint8 {
+	if addr.Is4() {
+		return unix.AF_INET
+	}
+	return unix.AF_INET6
+}
+
+func ruleTable(index int) uint32 {
+	return uint32(tableBase + index)
+}
+
+func resolveFamily() (family uint16, err error) {
+	conn, err := netlink.Dial(unix.NETLINK_GENERIC, nil)
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "wglink: Failed to open generic netlink"),
+		}
+		return
+	}
+	defer conn.Close()
+
+	ae := netlink.NewAttributeEncoder()
+	ae.String(unix.CTRL_ATTR_FAMILY_NAME, familyName)
+	attrs, err := ae.Encode()
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "wglink: Failed to encode attributes"),
+		}
+		return
+	}
+
+	msgs, err := conn.Execute(netlink.Message{
+		Header: netlink.Header{
+			Type:  unix.GENL_ID_CTRL,
+			Flags: netlink.Request,
+		},
+		Data: append([]byte{unix.CTRL_CMD_GETFAMILY, 1, 0, 0}, attrs...),
+	})
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "wglink: Failed to resolve wireguard family"),
+		}
+		return
+	}
+
+	for _, msg := range msgs {
+		if len(msg.Data) < 4 {
+			continue
+		}
+
+		ad, e := netlink.NewAttributeDecoder(msg.Data[4:])
+		if e != nil {
+			continue
+		}
+
+		for ad.Next() {
+			if ad.Type() == unix.CTRL_ATTR_FAMILY_ID {
+				family = ad.Uint16()
+			}
+		}
+	}
+
+	if family == 0 {
+		err = &errortypes.NotFoundError{
+			errors.New("wglink: Wireguard family not available"),
+		}
+		return
+	}
+
+	return
+}
+
+func execRoute(msgType netlink.HeaderType, flags netlink.HeaderFlags,
+	data []byte, ae *netlink.AttributeEncoder) (err error) {
+
+	if ae != nil {
+		attrs, e := ae.Encode()
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "wglink: Failed to encode attributes"),
+			}
+			return
+		}
+		data = append(data, attrs...)
+	}
+
+	conn, err := netlink.Dial(unix.NETLINK_ROUTE, nil)
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "wglink: Failed to open route netlink"),
+		}
+		return
+	}
+	defer conn.Close()
+
+	_, err = conn.Execute(netlink.Message{
+		Header: netlink.Header{
+			Type:  msgType,
+			Flags: netlink.Request | netlink.Acknowledge | flags,
+		},
+		Data: data,
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func createLink(iface string, mtu int) (err error) {
+	ae := netlink.NewAttributeEncoder()
+	ae.String(unix.IFLA_IFNAME, iface)
+	ae.Uint32(unix.IFLA_MTU, uint32(mtu))
+	ae.Nested(unix.IFLA_LINKINFO, func(nae *netlink.AttributeEncoder) error {
+		nae.String(unix.IFLA_INFO_KIND, familyName)
+		return nil
+	})
+
+	err = execRoute(unix.RTM_NEWLINK, netlink.Create|netlink.Excl,
+		ifInfoMsg(0, 0, 0), ae)
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "wglink: Failed to create interface"),
+		}
+		return
+	}
+
+	return
+}
+
+func deleteLink(iface string) (err error) {
+	ae := netlink.NewAttributeEncoder()
+	ae.String(unix.IFLA_IFNAME, iface)
+
+	err = execRoute(unix.RTM_DELLINK, 0, ifInfoMsg(0, 0, 0), ae)
+	if err != nil {
+		if isErrno(err, unix.ENODEV) {
+			err = nil
+			return
+		}
+
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "wglink: Failed to delete interface"),
+		}
+		return
+	}
+
+	return
+}
+
+func setLinkUp(index int) (err error) {
+	err = execRoute(unix.RTM_NEWLINK, 0,
+		ifInfoMsg(int32(index), unix.IFF_UP, unix.IFF_UP), nil)
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "wglink: Failed to set interface up"),
+		}
+		return
+	}
+
+	return
+}
+
+func addAddress(index int, prefix netip.Prefix) (err error) {
+	data := make([]byte, unix.SizeofIfAddrmsg)
+	data[0] = addrFamily(prefix.Addr())
+	data[1] = uint8(prefix.Bits())
+	nlenc.PutUint32(data[4:8], uint32(index))
+
+	ae := netlink.NewAttributeEncoder()
+	ae.Bytes(unix.IFA_LOCAL, prefix.Addr().AsSlice())
+	ae.Bytes(unix.IFA_ADDRESS, prefix.Addr().AsSlice())
+
+	err = execRoute(unix.RTM_NEWADDR, netlink.Create|netlink.Replace,
+		data, ae)
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrapf(err, "wglink: Failed to add address %s", prefix),
+		}
+		return
+	}
+
+	return
+}
+
+func addRoute(index int, prefix netip.Prefix, table uint32) (err error) {
+	data := make([]byte, unix.SizeofRtMsg)
+	data[0] = addrFamily(prefix.Addr())
+	data[1] = uint8(prefix.Bits())
+	data[5] = unix.RTPROT_BOOT
+	data[6] = unix.RT_SCOPE_LINK
+	data[7] = unix.RTN_UNICAST
+
+	ae := netlink.NewAttributeEncoder()
+	if prefix.Bits() != 0 {
+		ae.Bytes(unix.RTA_DST, prefix.Masked().Addr().AsSlice())
+	}
+	ae.Uint32(unix.RTA_OIF, uint32(index))
+	ae.Uint32(unix.RTA_TABLE, table)
+
+	err = execRoute(unix.RTM_NEWROUTE, netlink.Create|netlink.Replace,
+		data, ae)
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrapf(err, "wglink: Failed to add route %s", prefix),
+		}
+		return
+	}
+
+	return
+}
+
+func ruleMsg(family uint8, flags uint32) []byte {
+	data := make([]byte, 12)
+	data[0] = family
+	data[7] = unix.FR_ACT_TO_TBL
+	nlenc.PutUint32(data[8:12], flags)
+	return data
+}
+
+func addRules(index int, family uint8) (err error) {
+	table := ruleTable(index)
+	ae := netlink.NewAttributeEncoder()
+	ae.Uint32(unix.FRA_PRIORITY, suppressPrio)
+	ae.Uint32(unix.FRA_TABLE, unix.RT_TABLE_MAIN)
+	ae.Uint32(unix.FRA_SUPPRESS_PREFIXLEN, 0)
+
+	err = execRoute(unix.RTM_NEWRULE, netlink.Create|netlink.Excl,
+		ruleMsg(family, 0), ae)
+	if err != nil && !isErrno(err, unix.EEXIST) {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "wglink: Failed to add suppress rule"),
+		}
+		return
+	}
+
+	ae = netlink.NewAttributeEncoder()
+	ae.Uint32(unix.FRA_PRIORITY, fwmarkPrio)
+	ae.Uint32(unix.FRA_FWMARK, table)
+	ae.Uint32(unix.FRA_TABLE, table)
+
+	err = execRoute(unix.RTM_NEWRULE, netlink.Create|netlink.Excl,
+		ruleMsg(family, unix.FIB_RULE_INVERT), ae)
+	if err != nil && !isErrno(err, unix.EEXIST) {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "wglink: Failed to add fwmark rule"),
+		}
+		return
+	}
+	err = nil
+
+	return
+}
+
+func addThrowRoute(prefix netip.Prefix, table uint32) (err error) {
+	data := make([]byte, unix.SizeofRtMsg)
+	data[0] = addrFamily(prefix.Addr())
+	data[1] = uint8(prefix.Bits())
+	data[5] = unix.RTPROT_BOOT
+	data[6] = unix.RT_SCOPE_UNIVERSE
+	data[7] = unix.RTN_THROW
+
+	ae := netlink.NewAttributeEncoder()
+	if prefix.Bits() != 0 {
+		ae.Bytes(unix.RTA_DST, prefix.Masked().Addr().AsSlice())
+	}
+	ae.Uint32(unix.RTA_TABLE, table)
+
+	err = execRoute(unix.RTM_NEWROUTE, netlink.Create|netlink.Replace,
+		data, ae)
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrapf(err, "wglink: Failed to add throw route %s",
+				prefix),
+		}
+		return
+	}
+
+	return
+}
+
+func dumpRoute(msgType netlink.HeaderType, data []byte) (
+	msgs []netlink.Message, err error) {
+
+	conn, err := netlink.Dial(unix.NETLINK_ROUTE, nil)
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "wglink: Failed to open route netlink"),
+		}
+		return
+	}
+	defer conn.Close()
+
+	msgs, err = conn.Execute(netlink.Message{
+		Header: netlink.Header{
+			Type:  msgType,
+			Flags: netlink.Request | netlink.Dump,
+		},
+		Data: data,
+	})
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "wglink: Failed to dump routes"),
+		}
+		return
+	}
+
+	return
+}
+
+// msgTable returns the priority and table of a rule or route message
+func msgTable(data []byte, hdrLen int, prioType, tableType uint16) (
+	prio, table uint32) {
+
+	if len(data) < hdrLen {
+		return
+	}
+	table = uint32(data[4])
+
+	ad, err := netlink.NewAttributeDecoder(data[hdrLen:])
+	if err != nil {
+		return
+	}
+
+	for ad.Next() {
+		switch ad.Type() {
+		case prioType:
+			prio = ad.Uint32()
+		case tableType:
+			table = ad.Uint32()
+		}
+	}
+
+	return
+}
+
+// deleteRules removes the rules of the interface table, the shared
+// suppress rule is removed once no other interface rules remain
+func deleteRules(table uint32) {
+	for _, family := range []uint8{unix.AF_INET, unix.AF_INET6} {
+		msgs, err := dumpRoute(unix.RTM_GETRULE, ruleMsg(family, 0))
+		if err != nil {
+			continue
+		}
+
+		remaining := false
+		for _, msg := range msgs {
+			prio, rlTable := msgTable(msg.Data, 12,
+				unix.FRA_PRIORITY, unix.FRA_TABLE)
+			if prio != fwmarkPrio {
+				continue
+			}
+
+			if rlTable != table {
+				remaining = true
+				continue
+			}
+
+			_ = execRoute(unix.RTM_DELRULE, 0, msg.Data, nil)
+		}
+
+		if remaining {
+			continue
+		}
+
+		ae := netlink.NewAttributeEncoder()
+		ae.Uint32(unix.FRA_PRIORITY, suppressPrio)
+
+		_ = execRoute(unix.RTM_DELRULE, 0, ruleMsg(family, 0), ae)
+	}
+}
+
+func flushTable(table uint32) {
+	for _, family := range []uint8{unix.AF_INET, unix.AF_INET6} {
+		data := make([]byte, unix.SizeofRtMsg)
+		data[0] = family
+
+		msgs, err := dumpRoute(unix.RTM_GETROUTE, data)
+		if err != nil {
+			continue
+		}
+
+		for _, msg := range msgs {
+			_, rtTable := msgTable(msg.Data, unix.SizeofRtMsg,
+				0, unix.RTA_TABLE)
+			if rtTable != table {
+				continue
+			}
+
+			_ = execRoute(unix.RTM_DELROUTE, 0, msg.Data, nil)
+		}
+	}
+}
+
+// cleanRules removes the rules and routes left by interfaces that no
+// longer exist
+func cleanRules() {
+	tables := map[uint32]bool{}
+
+	for _, family := range []uint8{unix.AF_INET, unix.AF_INET6} {
+		msgs, err := dumpRoute(unix.RTM_GETRULE, ruleMsg(family, 0))
+		if err != nil {
+			continue
+		}
+
+		for _, msg := range msgs {
+			prio, table := msgTable(msg.Data, 12,
+				unix.FRA_PRIORITY, unix.FRA_TABLE)
+			if prio == fwmarkPrio && table > tableBase {
+				tables[table] = true
+			}
+		}
+	}
+
+	for table := range tables {
+		_, err := net.InterfaceByIndex(int(table - tableBase))
+		if err == nil {
+			continue
+		}
+
+		deleteRules(table)
+		flushTable(table)
+	}
+}
+
+func sockaddr(addr netip.AddrPort) []byte {
+	var data []byte
+
+	if addr.Addr().Is4() {
+		data = make([]byte, unix.SizeofSockaddrInet4)
+		nlenc.PutUint16(data[0:2], unix.AF_INET)
+		ip := addr.Addr().As4()
+		copy(data[4:8], ip[:])
+	} else {
+		data = make([]byte, unix.SizeofSockaddrInet6)
+		nlenc.PutUint16(data[0:2], unix.AF_INET6)
+		ip := addr.Addr().As16()
+		copy(data[8:24], ip[:])
+	}
+	binary.BigEndian.PutUint16(data[2:4], addr.Port())
+
+	return data
+}
+
+func setDevice(family uint16, conf *Config, prefixes []netip.Prefix,
+	fwmark uint32) (err error) {
+
+	privateKey, err := parseKey(conf.PrivateKey)
+	if err != nil {
+		return
+	}
+
+	publicKey, err := parseKey(conf.PublicKey)
+	if err != nil {
+		return
+	}
+
+	endpoint, err := parseEndpoint(conf.Endpoint)
+	if err != nil {
+		return
+	}
+
+	ae := netlink.NewAttributeEncoder()
+	ae.String(unix.WGDEVICE_A_IFNAME, conf.Iface)
+	ae.Bytes(unix.WGDEVICE_A_PRIVATE_KEY, privateKey)
+	ae.Uint32(unix.WGDEVICE_A_FLAGS, unix.WGDEVICE_F_REPLACE_PEERS)
+	ae.Uint32(unix.WGDEVICE_A_FWMARK, fwmark)
+	ae.Nested(unix.WGDEVICE_A_PEERS, func(pae *netlink.AttributeEncoder) error {
+		pae.Nested(0, func(nae *netlink.AttributeEncoder) error {
+			nae.Bytes(unix.WGPEER_A_PUBLIC_KEY, publicKey)
+			nae.Uint32(unix.WGPEER_A_FLAGS, unix.WGPEER_F_REPLACE_ALLOWEDIPS)
+			nae.Bytes(unix.WGPEER_A_ENDPOINT, sockaddr(endpoint))
+			nae.Nested(unix.WGPEER_A_ALLOWEDIPS,
+				func(aae *netlink.AttributeEncoder) error {
+					for i, prefix := range prefixes {
+						aae.Nested(uint16(i),
+							func(iae *netlink.AttributeEncoder) error {
+								iae.Uint16(unix.WGALLOWEDIP_A_FAMILY,
+									uint16(addrFamily(prefix.Addr())))
+								iae.Bytes(unix.WGALLOWEDIP_A_IPADDR,
+									prefix.Masked().Addr().AsSlice())
+								iae.Uint8(unix.WGALLOWEDIP_A_CIDR_MASK,
+									uint8(prefix.Bits()))
+								return nil
+							})
+					}
+					return nil
+				})
+			return nil
+		})
+		return nil
+	})
+
+	attrs, err := ae.Encode()
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "wglink: Failed to encode device attributes"),
+		}
+		return
+	}
+
+	conn, err := netlink.Dial(unix.NETLINK_GENERIC, nil)
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "wglink: Failed to open generic netlink"),
+		}
+		return
+	}
+	defer conn.Close()
+
+	_, err = conn.Execute(netlink.Message{
+		Header: netlink.Header{
+			Type:  netlink.HeaderType(family),
+			Flags: netlink.Request | netlink.Acknowledge,
+		},
+		Data: append(genlHeader(unix.WG_CMD_SET_DEVICE), attrs...),
+	})
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "wglink: Failed to configure device"),
+		}
+		return
+	}
+
+	return
+}
+
+func parsePrefixes(addrs []string) (prefixes []netip.Prefix, err error) {
+	prefixes = []netip.Prefix{}
+	for _, addr := range addrs {
+		prefix, e := parseAddress(addr)
+		if e != nil {
+			err = e
+			return
+		}
+		prefixes = append(prefixes, prefix)
+	}
+
+	return
+}
+
+func hasDefault(prefixes []netip.Prefix) bool {
+	for _, prefix := range prefixes {
+		if prefix.Bits() == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func Fwmark(iface string) (fwmark uint32, err error) {
+	ifc, err := net.InterfaceByName(iface)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "wglink: Failed to find interface"),
+		}
+		return
+	}
+
+	fwmark = ruleTable(ifc.Index)
+	return
+}
+
+func Up(conf *Config) (err error) {
+	family, err := resolveFamily()
+	if err != nil {
+		return
+	}
+
+	mtu := conf.Mtu
+	if mtu == 0 {
+		mtu = defaultMtu
+	}
+
+	prefixes, err := parsePrefixes(conf.AllowedIps)
+	if err != nil {
+		return
+	}
+
+	_ = Down(conf.Iface)
+	cleanRules()
+
+	err = createLink(conf.Iface, mtu)
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			_ = Down(conf.Iface)
+		}
+	}()
+
+	fwmark := uint32(0)
+	if hasDefault(prefixes) && !conf.NoDefaultRoute {
+		fwmark, err = Fwmark(conf.Iface)
+		if err != nil {
+			return
+		}
+	}
+
+	err = setDevice(family, conf, prefixes, fwmark)
+	if err != nil {
+		return
+	}
+
+	err = Configure(conf)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func Configure(conf *Config) (err error) {
+	addrs, err := parsePrefixes(conf.Addresses)
+	if err != nil {
+		return
+	}
+
+	prefixes, err := parsePrefixes(conf.AllowedIps)
+	if err != nil {
+		return
+	}
+
+	ifc, err := net.InterfaceByName(conf.Iface)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "wglink: Failed to find interface"),
+		}
+		return
+	}
+	table := ruleTable(ifc.Index)
+
+	for _, prefix := range addrs {
+		err = addAddress(ifc.Index, prefix)
+		if err != nil {
+			return
+		}
+	}
+
+	err = setLinkUp(ifc.Index)
+	if err != nil {
+		return
+	}
+
+	for _, prefix := range prefixes {
+		if prefix.Bits() != 0 {
+			err = addRoute(ifc.Index, prefix, unix.RT_TABLE_MAIN)
+			if err != nil {
+				return
+			}
+			continue
+		}
+
+		if conf.NoDefaultRoute {
+			continue
+		}
+
+		err = addRoute(ifc.Index, prefix, table)
+		if err != nil {
+			return
+		}
+
+		err = addRules(ifc.Index, addrFamily(prefix.Addr()))
+		if err != nil {
+			return
+		}
+
+		if prefix.Addr().Is4() {
+			_ = ioutil.WriteFile(srcValidMark, []byte("1"), 0644)
+		}
+	}
+
+	defaultRoute := hasDefault(prefixes) && !conf.NoDefaultRoute
+
+	if defaultRoute {
+		bypass, e := parsePrefixes(conf.BypassIps)
+		if e != nil {
+			err = e
+			return
+		}
+
+		for _, prefix := range bypass {
+			err = addThrowRoute(prefix, table)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	if len(conf.DnsServers) > 0 {
+		err = dns.Set(&dns.Config{
+			Iface:        conf.Iface,
+			Servers:      conf.DnsServers,
+			Domains:      conf.SearchDomains,
+			DefaultRoute: defaultRoute || len(conf.SearchDomains) == 0,
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func Down(iface string) (err error) {
+	if iface == "" {
+		return
+	}
+
+	dns.Clear(iface)
+
+	ifc, e := net.InterfaceByName(iface)
+	if e != nil {
+		return
+	}
+
+	table := ruleTable(ifc.Index)
+	deleteRules(table)
+
+	err = deleteLink(iface)
+	if err != nil {
+		return
+	}
+
+	flushTable(table)
+
+	return
+}
+
+func GetPeer(iface, publicKey string) (peer *Peer, err error) {
+	family, err := resolveFamily()
+	if err != nil {
+		return
+	}
+
+	ae := netlink.NewAttributeEncoder()
+	ae.String(unix.WGDEVICE_A_IFNAME, iface)
+	attrs, err := ae.Encode()
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "wglink: Failed to encode attributes"),
+		}
+		return
+	}
+
+	conn, err := netlink.Dial(unix.NETLINK_GENERIC, nil)
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "wglink: Failed to open generic netlink"),
+		}
+		return
+	}
+	defer conn.Close()
+
+	msgs, err := conn.Execute(netlink.Message{
+		Header: netlink.Header{
+			Type:  netlink.HeaderType(family),
+			Flags: netlink.Request | netlink.Dump,
+		},
+		Data: append(genlHeader(unix.WG_CMD_GET_DEVICE), attrs...),
+	})
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "wglink: Failed to get device"),
+		}
+		return
+	}
+
+	for _, msg := range msgs {
+		if len(msg.Data) < 4 {
+			continue
+		}
+
+		ad, e := netlink.NewAttributeDecoder(msg.Data[4:])
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "wglink: Failed to decode device"),
+			}
+			return
+		}
+
+		for ad.Next() {
+			if ad.Type() != unix.WGDEVICE_A_PEERS {
+				continue
+			}
+
+			ad.Nested(func(pad *netlink.AttributeDecoder) error {
+				for pad.Next() {
+					pad.Nested(func(nad *netlink.AttributeDecoder) error {
+						prPeer := &Peer{}
+
+						for nad.Next() {
+							switch nad.Type() {
+							case unix.WGPEER_A_PUBLIC_KEY:
+								prPeer.PublicKey = base64.StdEncoding.
+									EncodeToString(nad.Bytes())
+							case unix.WGPEER_A_LAST_HANDSHAKE_TIME:
+								data := nad.Bytes()
+								if len(data) >= 8 {
+									prPeer.LastHandshake = int64(
+										nlenc.Uint64(data[:8]))
+								}
+							case unix.WGPEER_A_RX_BYTES:
+								prPeer.RxBytes = nad.Uint64()
+							case unix.WGPEER_A_TX_BYTES:
+								prPeer.TxBytes = nad.Uint64()
+							}
+						}
+
+						if prPeer.PublicKey == publicKey {
+							peer = prPeer
+						}
+
+						return nil
+					})
+				}
+				return nil
+			})
+		}
+
+		err = ad.Err()
+		if err != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(err, "wglink: Failed to decode device peers"),
+			}
+			return
+		}
+	}
+
+	if peer == nil {
+		err = &errortypes.NotFoundError{
+			errors.New("wglink: Failed to find peer"),
+		}
+		return
+	}
+
+	return
+}